@@ -1,37 +1,88 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/batijo/poll-scraper/config"
 	"github.com/batijo/poll-scraper/models"
+	"github.com/batijo/poll-scraper/pipeline"
 	"github.com/batijo/poll-scraper/scraper"
+	"github.com/batijo/poll-scraper/snapshot"
 )
 
-func Data(cfg *config.Config) http.HandlerFunc {
+// Data serves the data of the latest scrape cycle published to store, with
+// ETag and Last-Modified validators so unchanged data costs clients a 304.
+// With ?fresh=1 the sources are scraped for the request instead. Sources
+// that served held data are listed in the X-Stale-Sources response header.
+func Data(cfg *config.Config, sc *scraper.Scraper, store *snapshot.Store) http.HandlerFunc {
+	live := liveData(cfg, sc)
 	return func(w http.ResponseWriter, r *http.Request) {
 		slog.Debug("HTTP request received", "method", r.Method, "remote", r.RemoteAddr)
-		data := scraper.ScrapeAll(cfg.Links, cfg.WithEq)
-		lines := cfg.FilterLinesZeroIndexed()
-		if len(lines) > 0 {
-			data = models.FilterData(lines, data)
+		if r.URL.Query().Get("fresh") == "1" {
+			live(w, r)
+			return
 		}
-		if len(cfg.AddLines) > 0 {
-			addLines := make([]models.Data, len(cfg.AddLines))
-			for i, l := range cfg.AddLines {
-				addLines[i] = models.Data{Name: l.Name, Value: l.Value}
-			}
-			data = models.AddLines(data, addLines)
+		snap, ok := latest(w, store)
+		if !ok {
+			return
 		}
-		if cfg.AddSum {
-			data = models.SumData(data, cfg.SumSymbols)
+		setStaleSources(w, snap.StaleSources())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("ETag", snap.ETag)
+		http.ServeContent(w, r, "", snap.Modified, bytes.NewReader(snap.Body))
+	}
+}
+
+// liveData scrapes all sources and runs the pipeline on every request.
+func liveData(cfg *config.Config, sc *scraper.Scraper) http.HandlerFunc {
+	opts := scraper.Options{Workers: cfg.MaxConcurrency, Hold: scraper.NewHolder(), Cache: scraper.NewCache()}
+	pl, plErr := pipeline.New(cfg)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if plErr != nil {
+			slog.Error("invalid processing pipeline", "err", plErr)
+			http.Error(w, plErr.Error(), http.StatusInternalServerError)
+			return
 		}
+		var data []models.Data
+		var stale []string
+		for _, res := range sc.ScrapeSources(cfg.ActiveSources(), opts) {
+			if res.Err != nil {
+				slog.Error("failed to scrape URL", "url", res.Source.URL, "err", res.Err)
+			}
+			if res.Stale {
+				stale = append(stale, res.Source.URL)
+			}
+			data = append(data, res.Data...)
+		}
+		setStaleSources(w, stale)
+		data = pl.Run(data).Data
 		slog.Debug("HTTP response", "lines", len(data))
 		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
 		if err := json.NewEncoder(w).Encode(data); err != nil {
 			slog.Error("failed to encode response", "err", err)
 		}
 	}
 }
+
+// latest returns the latest snapshot of store, answering 503 when no cycle
+// has completed yet.
+func latest(w http.ResponseWriter, store *snapshot.Store) (*snapshot.Snapshot, bool) {
+	snap, ok := store.Latest()
+	if !ok {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "no data scraped yet", http.StatusServiceUnavailable)
+	}
+	return snap, ok
+}
+
+func setStaleSources(w http.ResponseWriter, stale []string) {
+	if len(stale) > 0 {
+		w.Header().Set("X-Stale-Sources", strings.Join(stale, ", "))
+	}
+}
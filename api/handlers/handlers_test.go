@@ -1,25 +1,35 @@
 package handlers
 
 import (
+	"bufio"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/batijo/poll-scraper/config"
 	"github.com/batijo/poll-scraper/models"
+	"github.com/batijo/poll-scraper/scraper"
+	"github.com/batijo/poll-scraper/snapshot"
 )
 
 func TestData_ReturnsJSON(t *testing.T) {
 	cfg := &config.Config{
-		Links: []string{},
-		Port:  3000,
+		Sources: []config.Source{},
+		Port:    3000,
 	}
 
+	store := snapshot.NewStore()
+	if err := store.Publish(snapshot.Snapshot{Data: []models.Data{{Name: "A", Value: "1"}}}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
 	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
 	rec := httptest.NewRecorder()
 
-	Data(cfg)(rec, req)
+	Data(cfg, scraper.New(scraper.Settings{}), store)(rec, req)
 
 	if rec.Code != http.StatusOK {
 		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
@@ -36,17 +46,17 @@ func TestData_ReturnsJSON(t *testing.T) {
 
 func TestData_WithFilters(t *testing.T) {
 	cfg := &config.Config{
-		Links:       []string{},
-		Port:        3000,
-		FilterLines: []int{},
-		AddLines:    []config.AddLine{},
-		AddSum:      false,
+		Sources:  []config.Source{},
+		Port:     3000,
+		Filters:  []models.LineFilter{},
+		AddLines: []config.AddLine{},
+		AddSum:   false,
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req := httptest.NewRequest(http.MethodGet, "/?fresh=1", http.NoBody)
 	rec := httptest.NewRecorder()
 
-	Data(cfg)(rec, req)
+	Data(cfg, scraper.New(scraper.Settings{}), snapshot.NewStore())(rec, req)
 
 	if rec.Code != http.StatusOK {
 		t.Errorf("status = %d, want %d", rec.Code, http.StatusOK)
@@ -57,3 +67,274 @@ func TestData_WithFilters(t *testing.T) {
 		t.Errorf("failed to decode response: %v", err)
 	}
 }
+
+func TestData_HoldsLastGoodData(t *testing.T) {
+	var fail atomic.Bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`<p>Alice=100</p>`))
+	}))
+	defer ts.Close()
+	cfg := &config.Config{
+		Sources:   []config.Source{{URL: ts.URL, Mode: config.ModeEquals, Enabled: true, HoldLastGood: true}},
+		Extractor: config.ModeEquals,
+		Port:      3000,
+	}
+	handler := Data(cfg, scraper.New(scraper.Settings{}), snapshot.NewStore())
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/?fresh=1", http.NoBody))
+	fail.Store(true)
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/?fresh=1", http.NoBody))
+
+	if got := rec.Header().Get("X-Stale-Sources"); got != ts.URL {
+		t.Errorf("X-Stale-Sources = %q, want %q", got, ts.URL)
+	}
+	var data []models.Data
+	if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(data) != 1 || data[0].Value != "100" {
+		t.Errorf("data = %+v, want held line with value 100", data)
+	}
+}
+
+func TestData_SkipsFilteredCustomLines(t *testing.T) {
+	cfg := &config.Config{
+		Sources: []config.Source{},
+		Port:    3000,
+		AddLines: []config.AddLine{
+			{Name: "shown", Value: "1"},
+			{Name: "hidden", Value: "2", Filtered: true},
+		},
+	}
+	rec := httptest.NewRecorder()
+
+	Data(cfg, scraper.New(scraper.Settings{}), snapshot.NewStore())(rec, httptest.NewRequest(http.MethodGet, "/?fresh=1", http.NoBody))
+
+	var data []models.Data
+	if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(data) != 1 || data[0].Name != "shown" {
+		t.Errorf("data = %+v, want only the shown custom line", data)
+	}
+}
+
+func TestData_ServesSnapshotWithoutScraping(t *testing.T) {
+	var hits atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte(`<p>Alice=100</p>`))
+	}))
+	defer ts.Close()
+	cfg := &config.Config{
+		Sources:   []config.Source{{URL: ts.URL, Mode: config.ModeEquals, Enabled: true}},
+		Extractor: config.ModeEquals,
+		Port:      3000,
+	}
+	store := snapshot.NewStore()
+	if err := store.Publish(snapshot.Snapshot{Data: []models.Data{{Name: "Alice", Value: "90"}}}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	handler := Data(cfg, scraper.New(scraper.Settings{}), store)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("Last-Modified") == "" {
+		t.Errorf("missing validators: ETag %q, Last-Modified %q", etag, rec.Header().Get("Last-Modified"))
+	}
+	var data []models.Data
+	if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(data) != 1 || data[0].Value != "90" {
+		t.Errorf("data = %+v, want the snapshot", data)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("source was scraped %d times, want 0", hits.Load())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/?fresh=1", http.NoBody))
+	if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if hits.Load() != 1 || len(data) != 1 || data[0].Value != "100" {
+		t.Errorf("fresh data = %+v after %d hits, want live value 100", data, hits.Load())
+	}
+}
+
+func TestData_UnavailableBeforeFirstCycle(t *testing.T) {
+	cfg := &config.Config{Sources: []config.Source{}, Port: 3000}
+	rec := httptest.NewRecorder()
+
+	Data(cfg, scraper.New(scraper.Settings{}), snapshot.NewStore())(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func readEvent(t *testing.T, r *bufio.Reader) (id, data string) {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && data != "":
+			return id, data
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestStream_PushesSnapshots(t *testing.T) {
+	store := snapshot.NewStore()
+	_ = store.Publish(snapshot.Snapshot{Data: []models.Data{{Name: "A", Value: "1"}}})
+	ts := httptest.NewServer(Stream(store))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", got)
+	}
+	r := bufio.NewReader(resp.Body)
+
+	id, data := readEvent(t, r)
+	var payload models.DataPayload
+	if err := json.Unmarshal([]byte(data), &payload); err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	if id != "1" || len(payload.Data) != 1 || payload.Data[0].Value != "1" || payload.Timestamp == "" {
+		t.Errorf("first event = %s %+v, want id 1 with the latest snapshot", id, payload)
+	}
+
+	_ = store.Publish(snapshot.Snapshot{Data: []models.Data{{Name: "A", Value: "2"}}})
+	if id, data = readEvent(t, r); id != "2" || !strings.Contains(data, `"value":"2"`) {
+		t.Errorf("second event = %s %s, want id 2 with value 2", id, data)
+	}
+}
+
+func TestStream_SkipsSnapshotClientHas(t *testing.T) {
+	store := snapshot.NewStore()
+	_ = store.Publish(snapshot.Snapshot{Data: []models.Data{{Name: "A", Value: "1"}}})
+	ts := httptest.NewServer(Stream(store))
+	defer ts.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL, http.NoBody)
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	defer resp.Body.Close()
+	_ = store.Publish(snapshot.Snapshot{Data: []models.Data{{Name: "A", Value: "2"}}})
+
+	if id, _ := readEvent(t, bufio.NewReader(resp.Body)); id != "2" {
+		t.Errorf("first event id = %s, want 2", id)
+	}
+}
+
+func TestRaw_LabelsSources(t *testing.T) {
+	cfg := &config.Config{Sources: []config.Source{{URL: "http://a", Label: "Poll A", Enabled: true}}}
+	store := snapshot.NewStore()
+	_ = store.Publish(snapshot.Snapshot{
+		Data:    []models.Data{{Name: "Total", Value: "3"}},
+		RawData: []models.Data{{Name: "A", Value: "1", Source: "http://a"}, {Name: "B", Value: "2", Source: "http://b"}},
+	})
+	rec := httptest.NewRecorder()
+
+	Raw(cfg, store)(rec, httptest.NewRequest(http.MethodGet, "/raw", http.NoBody))
+
+	var lines []RawLine
+	if err := json.NewDecoder(rec.Body).Decode(&lines); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(lines) != 2 || lines[0].Label != "Poll A" || lines[1].Label != "http://b" || lines[0].Source != "http://a" {
+		t.Errorf("raw lines = %+v, want both scraped lines labeled", lines)
+	}
+}
+
+func TestStatus_ReportsLatestCycle(t *testing.T) {
+	store := snapshot.NewStore()
+	handler := Status(store)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/status", http.NoBody))
+	var status ScraperStatus
+	_ = json.NewDecoder(rec.Body).Decode(&status)
+	if status.State != snapshot.StateStopped || status.Cycle != 0 || status.LastCycle != "" || status.URLs == nil {
+		t.Errorf("status before first cycle = %+v", status)
+	}
+
+	store.SetState(snapshot.StateIdle)
+	_ = store.Publish(snapshot.Snapshot{Cycle: 4, Statuses: []models.URLStatus{{URL: "http://a", HasData: true}}})
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/status", http.NoBody))
+	_ = json.NewDecoder(rec.Body).Decode(&status)
+	if status.State != snapshot.StateIdle || status.Cycle != 4 || status.LastCycle == "" || len(status.URLs) != 1 {
+		t.Errorf("status = %+v, want cycle 4 with one URL", status)
+	}
+}
+
+func TestNotReady(t *testing.T) {
+	cfg := &config.Config{UpdateInterval: 20000}
+	now := time.Unix(1000, 0)
+	ok := []models.URLStatus{{URL: "http://a", HasData: true}, {URL: "http://b", Error: true}}
+	failed := []models.URLStatus{{URL: "http://a", Error: true}}
+
+	tests := []struct {
+		name  string
+		snap  *snapshot.Snapshot
+		state string
+		ready bool
+	}{
+		{"no snapshot", nil, snapshot.StateScraping, false},
+		{"recent", &snapshot.Snapshot{Published: now.Add(-50 * time.Second), Statuses: ok}, snapshot.StateIdle, true},
+		{"too old", &snapshot.Snapshot{Published: now.Add(-70 * time.Second), Statuses: ok}, snapshot.StateIdle, false},
+		{"scraper error", &snapshot.Snapshot{Published: now, Statuses: ok}, snapshot.StateError, false},
+		{"no source data", &snapshot.Snapshot{Published: now, Statuses: failed}, snapshot.StateIdle, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := notReady(cfg, tt.snap, tt.state, now)
+			if (reason == "") != tt.ready {
+				t.Errorf("notReady() = %q, want ready %v", reason, tt.ready)
+			}
+		})
+	}
+}
+
+func TestReady_UnavailableBeforeFirstCycle(t *testing.T) {
+	rec := httptest.NewRecorder()
+
+	Ready(&config.Config{}, snapshot.NewStore())(rec, httptest.NewRequest(http.MethodGet, "/readyz", http.NoBody))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
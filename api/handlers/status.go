@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+	"github.com/batijo/poll-scraper/snapshot"
+)
+
+const (
+	// Data is considered stale once this many update intervals pass
+	// without a new cycle, but never sooner than minReadyAge.
+	readyIntervals = 3
+	minReadyAge    = 30 * time.Second
+)
+
+// RawLine is a scraped line with the label of its source.
+type RawLine struct {
+	models.Data
+	Label string `json:"label,omitempty"`
+}
+
+// ScraperStatus is the response of the /status endpoint.
+type ScraperStatus struct {
+	State     string             `json:"state"`
+	Cycle     int                `json:"cycle"`
+	LastCycle string             `json:"lastCycle,omitempty"`
+	URLs      []models.URLStatus `json:"urls"`
+}
+
+// Raw serves the unfiltered data of the latest scrape cycle, before custom
+// lines, filters and the sum are applied.
+func Raw(cfg *config.Config, store *snapshot.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		snap, ok := latest(w, store)
+		if !ok {
+			return
+		}
+		lines := make([]RawLine, len(snap.RawData))
+		for i, d := range snap.RawData {
+			lines[i] = RawLine{Data: d}
+			if d.Source != "" {
+				src := cfg.SourceByURL(d.Source)
+				lines[i].Label = src.Name()
+			}
+		}
+		setStaleSources(w, snap.StaleSources())
+		w.Header().Set("Cache-Control", "no-cache")
+		writeJSON(w, lines)
+	}
+}
+
+// Status serves the scraper state and the result of the latest cycle
+// for each source.
+func Status(store *snapshot.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		status := ScraperStatus{State: store.State(), URLs: []models.URLStatus{}}
+		if snap, ok := store.Latest(); ok {
+			status.Cycle = snap.Cycle
+			status.LastCycle = snap.Published.Format(time.RFC3339)
+			if snap.Statuses != nil {
+				status.URLs = snap.Statuses
+			}
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		writeJSON(w, status)
+	}
+}
+
+// Health reports that the server is up.
+func Health(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = fmt.Fprintln(w, "ok")
+}
+
+// Ready reports whether the data is fit to go to air: a cycle has completed
+// recently, the scraper is not in error and at least one source has data.
+// Otherwise it answers 503 with the reason.
+func Ready(cfg *config.Config, store *snapshot.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		snap, _ := store.Latest()
+		if reason := notReady(cfg, snap, store.State(), time.Now()); reason != "" {
+			http.Error(w, reason, http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = fmt.Fprintln(w, "ready")
+	}
+}
+
+// notReady returns why the data of snap is not ready at now, or "" when it
+// is.
+func notReady(cfg *config.Config, snap *snapshot.Snapshot, state string, now time.Time) string {
+	if snap == nil {
+		return "no data scraped yet"
+	}
+	if state == snapshot.StateError || state == snapshot.StateStopped {
+		return "scraper is " + state
+	}
+	maxAge := max(readyIntervals*time.Duration(cfg.UpdateInterval)*time.Millisecond, minReadyAge)
+	if age := now.Sub(snap.Published); age > maxAge {
+		return fmt.Sprintf("last cycle was %s ago", age.Round(time.Second))
+	}
+	if len(snap.Statuses) == 0 {
+		return ""
+	}
+	for _, st := range snap.Statuses {
+		if st.HasData {
+			return ""
+		}
+	}
+	return "no source returned data"
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("failed to encode response", "err", err)
+	}
+}
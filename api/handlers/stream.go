@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/batijo/poll-scraper/snapshot"
+)
+
+const (
+	streamRetry     = time.Second
+	streamHeartbeat = 15 * time.Second
+)
+
+// Stream pushes the data of every published snapshot as Server-Sent Events,
+// with the payload of the polled:data UI event. Each event carries the
+// snapshot's sequence number as its ID. A connecting client gets the latest
+// snapshot right away, unless its Last-Event-ID shows it already has it.
+func Stream(store *snapshot.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		updates, cancel := store.Subscribe()
+		defer cancel()
+		slog.Debug("event stream opened", "remote", r.RemoteAddr)
+		defer slog.Debug("event stream closed", "remote", r.RemoteAddr)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds()); err != nil {
+			return
+		}
+
+		var sent uint64
+		if id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+			sent = id
+		}
+		send := func(snap *snapshot.Snapshot) error {
+			if snap.Seq == sent {
+				return nil
+			}
+			payload, err := json.Marshal(snap.Payload())
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", snap.Seq, payload); err != nil {
+				return err
+			}
+			sent = snap.Seq
+			return rc.Flush()
+		}
+		if err := rc.Flush(); err != nil {
+			slog.Error("event stream not supported", "err", err)
+			return
+		}
+		if snap, ok := store.Latest(); ok {
+			if err := send(snap); err != nil {
+				return
+			}
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+		for {
+			var err error
+			select {
+			case <-r.Context().Done():
+				return
+			case snap := <-updates:
+				err = send(snap)
+			case <-heartbeat.C:
+				if _, err = fmt.Fprint(w, ": ping\n\n"); err == nil {
+					err = rc.Flush()
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
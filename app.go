@@ -8,22 +8,33 @@ import (
 	"net/http"
 	"os"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 
 	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/hub"
 	"github.com/batijo/poll-scraper/models"
+	"github.com/batijo/poll-scraper/pipeline"
 	"github.com/batijo/poll-scraper/scraper"
 	"github.com/batijo/poll-scraper/server"
+	"github.com/batijo/poll-scraper/snapshot"
 	"github.com/batijo/poll-scraper/utils"
 	"github.com/batijo/poll-scraper/utils/file"
 )
 
 type App struct {
-	ctx            context.Context
+	ctx context.Context
+	// mu guards cfg and scraper, which are replaced rather than modified
+	// so the writer and server can keep using the ones they were started
+	// with.
+	mu             sync.RWMutex
 	cfg            *config.Config
 	srv            *server.Server
+	scraper        *scraper.Scraper
+	snapshots      *snapshot.Store
+	hub            *hub.Hub
 	stopWriter     context.CancelFunc
 	scraperRunning bool
 }
@@ -41,6 +52,9 @@ func (a *App) Startup(ctx context.Context) {
 		return
 	}
 	a.cfg = cfg
+	a.scraper = scraper.New(scraper.SettingsFromConfig(cfg))
+	a.snapshots = snapshot.NewStore()
+	a.hub = hub.New(a.snapshots, a)
 
 	if err := a.initLogger(cfg.Debug); err != nil {
 		runtime.LogFatal(ctx, fmt.Sprintf("failed to init logger: %v", err))
@@ -48,7 +62,7 @@ func (a *App) Startup(ctx context.Context) {
 	}
 
 	slog.Info("config loaded",
-		"urls", len(cfg.Links),
+		"urls", len(cfg.ActiveSources()),
 		"server_enabled", cfg.EnableServer,
 		"interval", cfg.UpdateInterval,
 		"debug", cfg.Debug,
@@ -64,24 +78,43 @@ func (a *App) Startup(ctx context.Context) {
 
 func (a *App) Shutdown(ctx context.Context) {
 	slog.Info("application shutting down")
+	if sc := a.currentScraper(); sc != nil {
+		sc.Close()
+	}
 }
 
 func (a *App) GetConfig() *config.Config {
+	return a.config()
+}
+
+func (a *App) config() *config.Config {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 	return a.cfg
 }
 
+func (a *App) currentScraper() *scraper.Scraper {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.scraper
+}
+
 //nolint:gocritic // Wails binding requires value receiver for correct TypeScript codegen
 func (a *App) UpdateConfig(cfg config.Config) error {
 	slog.Info("config update requested")
 
+	// Saving and swapping under one lock keeps a filter migration from
+	// overwriting this config with a copy of the old one
+	a.mu.Lock()
 	if err := cfg.Save("config.json"); err != nil {
+		a.mu.Unlock()
 		slog.Error("failed to save config", "err", err)
 		return err
 	}
-	slog.Debug("config saved to disk")
-
 	oldCfg := a.cfg
 	a.cfg = &cfg
+	a.mu.Unlock()
+	slog.Debug("config saved to disk")
 
 	// Log what changed
 	a.logConfigChanges(oldCfg, &cfg)
@@ -92,11 +125,22 @@ func (a *App) UpdateConfig(cfg config.Config) error {
 		a.StopScraper()
 	}
 
+	// Rebuild the scraper only if its transport settings changed, so
+	// connections survive unrelated config updates
+	a.mu.Lock()
+	if settings := scraper.SettingsFromConfig(&cfg); settings != a.scraper.Settings() {
+		slog.Debug("HTTP settings changed, rebuilding scraper")
+		a.scraper.Close()
+		a.scraper = scraper.New(settings)
+	}
+	a.scraper.Prune(cfg.ActiveSources())
+	a.mu.Unlock()
+
 	// Reinit output files if paths or toggles changed
 	if oldCfg.WriteToCSV != cfg.WriteToCSV || oldCfg.CSVPath != cfg.CSVPath ||
 		oldCfg.WriteToTXT != cfg.WriteToTXT || oldCfg.TXTPath != cfg.TXTPath {
 		slog.Debug("output file config changed, reinitializing")
-		if err := file.InitFiles(a.cfg); err != nil {
+		if err := file.InitFiles(&cfg); err != nil {
 			slog.Error("failed to reinit files", "err", err)
 		}
 	}
@@ -126,11 +170,12 @@ func (a *App) StartScraper() error {
 	}
 	slog.Info("starting scraper")
 
-	if a.cfg.EnableServer {
-		a.startServer()
+	cfg := a.config()
+	if cfg.EnableServer {
+		a.startServer(cfg)
 	}
 
-	stopWriter, err := file.StartWriting(a.cfg, a)
+	stopWriter, err := file.StartWriting(cfg, a.currentScraper(), a.snapshots, a)
 	if err != nil {
 		slog.Error("failed to start scraper", "err", err)
 		a.stopServer()
@@ -152,7 +197,7 @@ func (a *App) StopScraper() {
 	}
 	a.stopServer()
 	a.scraperRunning = false
-	a.EmitScraperState("stopped")
+	a.EmitScraperState(snapshot.StateStopped)
 }
 
 func (a *App) IsScraperRunning() bool {
@@ -163,49 +208,64 @@ func (a *App) RequestScraperStop() {
 	go a.StopScraper()
 }
 
+// MigrateFilterLines converts the legacy filter_lines positions into key
+// filters using rawData from a complete scrape, saves the config and tells
+// the UI to reload it. Nothing is restarted: the running writer and server
+// keep the positional filters, which select the same lines.
+func (a *App) MigrateFilterLines(rawData []models.Data) {
+	a.mu.Lock()
+	cfg := *a.cfg
+	if !cfg.MigrateFilterLines(rawData) {
+		a.mu.Unlock()
+		return
+	}
+	if err := cfg.Save("config.json"); err != nil {
+		a.mu.Unlock()
+		slog.Error("failed to save migrated filters", "err", err)
+		return
+	}
+	a.cfg = &cfg
+	a.mu.Unlock()
+	slog.Info("migrated filter_lines to filters", "count", len(cfg.Filters))
+	// Only the UI is told, as the config holds source credentials
+	runtime.EventsEmit(a.ctx, "polled:config")
+}
+
 func (a *App) PreviewScrape() models.PreviewResult {
 	slog.Debug("preview scrape requested")
 
-	lines := a.cfg.FilterLinesZeroIndexed()
+	cfg := a.config()
 	var rawData []models.Data
-	statuses := make([]models.URLStatus, 0, len(a.cfg.Links))
-
-	for _, link := range a.cfg.Links {
-		urlData := scraper.ScrapeURL(link, a.cfg.WithEq)
-		statuses = append(statuses, models.URLStatus{
-			URL:       link,
-			HasData:   len(urlData) > 0,
-			LineCount: len(urlData),
-		})
-		if len(urlData) == 0 {
-			slog.Warn("no data from URL", "url", link)
+	sources := cfg.ActiveSources()
+	statuses := make([]models.URLStatus, 0, len(sources))
+	complete := true
+
+	for _, res := range a.currentScraper().ScrapeSources(sources, scraper.Options{Workers: cfg.MaxConcurrency}) {
+		statuses = append(statuses, res.Status())
+		if res.Err != nil {
+			slog.Error("failed to scrape URL", "url", res.Source.URL, "err", res.Err)
+			complete = false
+		} else if len(res.Data) == 0 {
+			slog.Warn("no data from URL", "url", res.Source.URL)
 		}
-		rawData = append(rawData, urlData...)
+		rawData = append(rawData, res.Data...)
 	}
 
-	// rawData = URL-scraped data only (for frontend filter modal)
-	processedData := make([]models.Data, len(rawData))
-	copy(processedData, rawData)
-
-	if len(lines) > 0 {
-		processedData = models.FilterData(lines, processedData)
+	if complete {
+		a.MigrateFilterLines(rawData)
 	}
 
-	// Add non-filtered custom lines after URL filtering
-	if len(a.cfg.AddLines) > 0 {
-		var addLines []models.Data
-		for _, l := range a.cfg.AddLines {
-			if !l.Filtered {
-				addLines = append(addLines, models.Data{Name: l.Name, Value: l.Value})
-			}
-		}
-		if len(addLines) > 0 {
-			processedData = models.AddLines(processedData, addLines)
-		}
-	}
-	if a.cfg.AddSum {
-		processedData = models.SumData(processedData, a.cfg.SumSymbols)
+	// rawData = URL-scraped data only (for frontend filter modal), with the
+	// filter decision of each of its lines
+	var processed pipeline.Result
+	if pl, err := pipeline.New(cfg); err != nil {
+		slog.Error("invalid processing pipeline", "err", err)
+		a.EmitScraperError(fmt.Sprintf("invalid processing pipeline: %v", err))
+	} else {
+		processed = pl.Run(rawData)
 	}
+	processedData := processed.Data
+	decisions := processed.Decisions
 
 	if rawData == nil {
 		rawData = []models.Data{}
@@ -213,13 +273,17 @@ func (a *App) PreviewScrape() models.PreviewResult {
 	if processedData == nil {
 		processedData = []models.Data{}
 	}
+	if decisions == nil {
+		decisions = []models.LineDecision{}
+	}
 
 	slog.Debug("preview scrape complete", "raw_lines", len(rawData), "processed_lines", len(processedData))
 
 	return models.PreviewResult{
-		RawData:  rawData,
-		Data:     processedData,
-		Statuses: statuses,
+		RawData:   rawData,
+		Data:      processedData,
+		Decisions: decisions,
+		Statuses:  statuses,
 	}
 }
 
@@ -234,11 +298,14 @@ func (a *App) logConfigChanges(oldCfg, newCfg *config.Config) {
 	if oldCfg.IP != newCfg.IP {
 		slog.Info("config changed", "field", "ip", "old", oldCfg.IP, "new", newCfg.IP)
 	}
+	if oldCfg.MaxConcurrency != newCfg.MaxConcurrency {
+		slog.Info("config changed", "field", "max_concurrency", "old", oldCfg.MaxConcurrency, "new", newCfg.MaxConcurrency)
+	}
 	if oldCfg.Port != newCfg.Port {
 		slog.Info("config changed", "field", "port", "old", oldCfg.Port, "new", newCfg.Port)
 	}
-	if oldCfg.WithEq != newCfg.WithEq {
-		slog.Info("config changed", "field", "with_eq", "old", oldCfg.WithEq, "new", newCfg.WithEq)
+	if oldCfg.Extractor != newCfg.Extractor {
+		slog.Info("config changed", "field", "extractor", "old", oldCfg.Extractor, "new", newCfg.Extractor)
 	}
 	if oldCfg.WriteToCSV != newCfg.WriteToCSV {
 		slog.Info("config changed", "field", "write_to_csv", "old", oldCfg.WriteToCSV, "new", newCfg.WriteToCSV)
@@ -267,14 +334,23 @@ func (a *App) logConfigChanges(oldCfg, newCfg *config.Config) {
 	if oldCfg.SumSymbols != newCfg.SumSymbols {
 		slog.Info("config changed", "field", "sum_symbols", "old", oldCfg.SumSymbols, "new", newCfg.SumSymbols)
 	}
-	if !reflect.DeepEqual(oldCfg.Links, newCfg.Links) {
-		slog.Info("config changed", "field", "links", "old_count", len(oldCfg.Links), "new_count", len(newCfg.Links))
+	if oldCfg.SumFormat() != newCfg.SumFormat() {
+		slog.Info("config changed", "field", "sum_format", "old", oldCfg.SumFormat(), "new", newCfg.SumFormat())
+	}
+	if !reflect.DeepEqual(oldCfg.Sources, newCfg.Sources) {
+		slog.Info("config changed", "field", "sources", "old_count", len(oldCfg.Sources), "new_count", len(newCfg.Sources))
 	}
 	if !reflect.DeepEqual(oldCfg.Domains, newCfg.Domains) {
 		slog.Info("config changed", "field", "domains", "old_count", len(oldCfg.Domains), "new_count", len(newCfg.Domains))
 	}
-	if !reflect.DeepEqual(oldCfg.FilterLines, newCfg.FilterLines) {
-		slog.Info("config changed", "field", "filter_lines", "old_count", len(oldCfg.FilterLines), "new_count", len(newCfg.FilterLines))
+	if !reflect.DeepEqual(oldCfg.Filters, newCfg.Filters) {
+		slog.Info("config changed", "field", "filters", "old_count", len(oldCfg.Filters), "new_count", len(newCfg.Filters))
+	}
+	if !reflect.DeepEqual(oldCfg.Pipeline, newCfg.Pipeline) {
+		slog.Info("config changed", "field", "pipeline", "old", oldCfg.Pipeline, "new", newCfg.Pipeline)
+	}
+	if !reflect.DeepEqual(oldCfg.FilterRules, newCfg.FilterRules) {
+		slog.Info("config changed", "field", "filter_rules", "old_count", len(oldCfg.FilterRules), "new_count", len(newCfg.FilterRules))
 	}
 	if !reflect.DeepEqual(oldCfg.AddLines, newCfg.AddLines) {
 		slog.Info("config changed", "field", "add_lines", "old_count", len(oldCfg.AddLines), "new_count", len(newCfg.AddLines))
@@ -286,37 +362,30 @@ func (a *App) logConfigChanges(oldCfg, newCfg *config.Config) {
 }
 
 func (a *App) EmitScraperData(data, rawData []models.Data) {
-	if data == nil {
-		data = []models.Data{}
-	}
-	if rawData == nil {
-		rawData = []models.Data{}
-	}
-	payload := map[string]interface{}{
-		"data":      data,
-		"rawData":   rawData,
-		"timestamp": time.Now().Format(time.RFC3339),
-	}
-	runtime.EventsEmit(a.ctx, "polled:data", payload)
+	a.emit(hub.EventData, models.NewDataPayload(data, rawData, time.Now()))
 }
 
 func (a *App) EmitScraperState(state string) {
-	runtime.EventsEmit(a.ctx, "polled:state", state)
+	a.snapshots.SetState(state)
+	a.emit(hub.EventState, state)
 }
 
 func (a *App) PreviewURL(url string) []models.Data {
 	slog.Debug("previewing URL", "url", url)
-	data := scraper.ScrapeURL(url, a.cfg.WithEq)
-	slog.Debug("preview complete", "url", url, "lines", len(data))
-	return data
+	res := a.currentScraper().ScrapeSource(a.config().SourceByURL(url))
+	if res.Err != nil {
+		slog.Error("failed to preview URL", "url", url, "err", res.Err)
+	}
+	slog.Debug("preview complete", "url", url, "lines", len(res.Data), "status", res.StatusCode)
+	return res.Data
 }
 
 func (a *App) EmitURLStatus(statuses []models.URLStatus) {
-	runtime.EventsEmit(a.ctx, "polled:url-status", statuses)
+	a.emit(hub.EventURLStatus, statuses)
 }
 
 func (a *App) EmitLog(entry utils.LogEntry) {
-	runtime.EventsEmit(a.ctx, "polled:log", entry)
+	a.emit(hub.EventLog, entry)
 }
 
 func (a *App) EmitScraperError(message string) {
@@ -324,12 +393,20 @@ func (a *App) EmitScraperError(message string) {
 		"message":   message,
 		"timestamp": time.Now().Unix(),
 	}
-	runtime.EventsEmit(a.ctx, "polled:error", payload)
+	a.emit(hub.EventError, payload)
+}
+
+// emit sends an event to the frontend and to WebSocket clients.
+func (a *App) emit(event string, payload any) {
+	runtime.EventsEmit(a.ctx, event, payload)
+	if a.hub != nil {
+		a.hub.Publish(event, payload)
+	}
 }
 
-func (a *App) startServer() {
-	srv := server.New(a.cfg)
-	srv.Addr = fmt.Sprintf("%s:%d", a.cfg.IP, a.cfg.Port)
+func (a *App) startServer(cfg *config.Config) {
+	srv := server.New(cfg, a.currentScraper(), a.snapshots, a.hub)
+	srv.Addr = fmt.Sprintf("%s:%d", cfg.IP, cfg.Port)
 	a.srv = srv
 	go func() {
 		slog.Info("server starting", "address", srv.Addr)
@@ -345,6 +422,8 @@ func (a *App) stopServer() {
 		if err := a.srv.Close(); err != nil {
 			slog.Error("failed to stop server", "err", err)
 		}
+		// Close does not close hijacked WebSocket connections
+		a.hub.Close()
 		a.srv = nil
 	}
 }
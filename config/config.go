@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,14 +10,51 @@ import (
 	"path/filepath"
 	"sort"
 
+	"github.com/batijo/poll-scraper/models"
 	"github.com/batijo/poll-scraper/utils"
 )
 
 const (
 	defaultPort           = 3000
 	defaultUpdateInterval = 1000
+	defaultMaxConcurrency = 4
+	defaultMaxIdleConns   = 16
+	defaultIdleTimeout    = 90000
+	defaultTLSMinVersion  = "1.2"
+	defaultExtractor      = ModeEquals
 )
 
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Built-in extractor modes.
+const (
+	ModeEquals = "equals"
+	ModeTable  = "table"
+	ModeCSS    = "css"
+	ModeXPath  = "xpath"
+	ModeJSON   = "json"
+	ModeRegex  = "regex"
+)
+
+// Built-in processing pipeline stages.
+const (
+	StageFilter  = "filter"
+	StageAdd     = "add"
+	StageCompute = "compute"
+	StageSum     = "sum"
+	StageFormat  = "format"
+)
+
+// DefaultPipeline returns the stage order used when the pipeline is not set.
+func DefaultPipeline() []string {
+	return []string{StageFilter, StageAdd, StageCompute, StageSum}
+}
+
 type AddLine struct {
 	Name     string `json:"name"`
 	Value    string `json:"value"`
@@ -24,38 +62,69 @@ type AddLine struct {
 }
 
 type Config struct {
-	Links                 []string  `json:"links"`
-	Port                  int       `json:"port"`
-	IP                    string    `json:"ip"`
-	Domains               []string  `json:"domains"`
-	EnableServer          bool      `json:"enable_server"`
-	WithEq                bool      `json:"with_eq"`
-	FilterLines           []int     `json:"filter_lines"`
-	AddLines              []AddLine `json:"add_lines"`
-	AddSum                bool      `json:"add_sum"`
-	SumSymbols            string    `json:"sum_symbols"`
-	UpdateInterval        int       `json:"update_interval"`
-	WriteToCSV            bool      `json:"write_to_csv"`
-	CSVPath               string    `json:"csv_path"`
-	WriteToTXT            bool      `json:"write_to_txt"`
-	TXTPath               string    `json:"txt_path"`
-	TXTEncoding           string    `json:"txt_encoding"`
-	DatasetName           string    `json:"dataset_name"`
-	Debug                 bool      `json:"debug"`
-	StopOnLineCountChange bool      `json:"stop_on_line_count_change"`
+	Sources               []Source            `json:"sources"`
+	Port                  int                 `json:"port"`
+	IP                    string              `json:"ip"`
+	Domains               []string            `json:"domains"`
+	EnableServer          bool                `json:"enable_server"`
+	Extractor             string              `json:"extractor"`
+	Filters               []models.LineFilter `json:"filters"`
+	FilterRules           []models.FilterRule `json:"filter_rules"`
+	AddLines              []AddLine           `json:"add_lines"`
+	Pipeline              []string            `json:"pipeline"`
+	AddSum                bool                `json:"add_sum"`
+	SumSymbols            string              `json:"sum_symbols"`
+	SumDecimals           *int                `json:"sum_decimals,omitempty"`
+	SumDecimalSeparator   string              `json:"sum_decimal_separator"`
+	SumThousandsSeparator string              `json:"sum_thousands_separator"`
+	SumSymbolPosition     string              `json:"sum_symbol_position"`
+	UpdateInterval        int                 `json:"update_interval"`
+	MaxConcurrency        int                 `json:"max_concurrency"`
+	UserAgent             string              `json:"user_agent"`
+	MaxIdleConns          int                 `json:"max_idle_conns"`
+	IdleConnTimeout       int                 `json:"idle_conn_timeout"`
+	TLSMinVersion         string              `json:"tls_min_version"`
+	TLSSkipVerify         bool                `json:"tls_skip_verify"`
+	Proxy                 *Proxy              `json:"proxy,omitempty"`
+	NoProxy               []string            `json:"no_proxy"`
+	WriteToCSV            bool                `json:"write_to_csv"`
+	CSVPath               string              `json:"csv_path"`
+	WriteToTXT            bool                `json:"write_to_txt"`
+	TXTPath               string              `json:"txt_path"`
+	TXTEncoding           string              `json:"txt_encoding"`
+	DatasetName           string              `json:"dataset_name"`
+	Debug                 bool                `json:"debug"`
+	StopOnLineCountChange bool                `json:"stop_on_line_count_change"`
+
+	// Deprecated: replaced by Extractor, migrated on load.
+	WithEq *bool `json:"with_eq,omitempty"`
+	// Deprecated: replaced by Sources, migrated on load.
+	Links []string `json:"links,omitempty"`
+	// Deprecated: replaced by Filters, migrated by MigrateFilterLines once a
+	// scrape completes without errors.
+	FilterLines []int `json:"filter_lines,omitempty"`
 }
 
 func defaultConfig() *Config {
 	return &Config{
-		Links:          []string{},
-		Port:           defaultPort,
-		IP:             "localhost",
-		Domains:        []string{},
-		EnableServer:   true,
-		WithEq:         true,
-		FilterLines:    []int{},
-		AddLines:       []AddLine{},
-		UpdateInterval: defaultUpdateInterval,
+		Sources:         []Source{},
+		Port:            defaultPort,
+		IP:              "localhost",
+		Domains:         []string{},
+		EnableServer:    true,
+		Extractor:       defaultExtractor,
+		Filters:         []models.LineFilter{},
+		FilterRules:     []models.FilterRule{},
+		AddLines:        []AddLine{},
+		Pipeline:        DefaultPipeline(),
+		UpdateInterval:  defaultUpdateInterval,
+		MaxConcurrency:  defaultMaxConcurrency,
+		MaxIdleConns:    defaultMaxIdleConns,
+		IdleConnTimeout: defaultIdleTimeout,
+		TLSMinVersion:   defaultTLSMinVersion,
+
+		SumDecimalSeparator: ".",
+		SumSymbolPosition:   SymbolSuffix,
 	}
 }
 
@@ -87,6 +156,10 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	cfg.migrate()
+	if err := cfg.openSecrets(path); err != nil {
+		return nil, err
+	}
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
@@ -94,8 +167,9 @@ func Load(path string) (*Config, error) {
 	cfg.applyDefaults()
 	cfg.sortFilters()
 	slog.Debug("config loaded",
-		"links", len(cfg.Links),
-		"filter_lines", len(cfg.FilterLines),
+		"sources", len(cfg.Sources),
+		"filters", len(cfg.Filters),
+		"filter_rules", len(cfg.FilterRules),
 		"add_lines", len(cfg.AddLines),
 		"server", cfg.EnableServer,
 	)
@@ -109,6 +183,29 @@ func (c *Config) validate() error {
 	if c.UpdateInterval < 0 {
 		return fmt.Errorf("update_interval cannot be negative")
 	}
+	if c.MaxConcurrency < 0 {
+		return fmt.Errorf("max_concurrency cannot be negative")
+	}
+	if c.MaxIdleConns < 0 {
+		return fmt.Errorf("max_idle_conns cannot be negative")
+	}
+	if c.IdleConnTimeout < 0 {
+		return fmt.Errorf("idle_conn_timeout cannot be negative")
+	}
+	if _, ok := tlsVersions[c.TLSMinVersion]; !ok && c.TLSMinVersion != "" {
+		return fmt.Errorf("tls_min_version must be one of 1.0, 1.1, 1.2 or 1.3")
+	}
+	if c.Proxy != nil {
+		if err := c.Proxy.validate(); err != nil {
+			return fmt.Errorf("proxy: %w", err)
+		}
+	}
+	if err := validateNoProxy(c.NoProxy); err != nil {
+		return err
+	}
+	if err := c.validateSum(); err != nil {
+		return err
+	}
 	if c.WriteToCSV && c.CSVPath == "" {
 		return fmt.Errorf("csv_path is required when write_to_csv is true")
 	}
@@ -118,10 +215,30 @@ func (c *Config) validate() error {
 	if c.WriteToTXT && c.DatasetName == "" {
 		return fmt.Errorf("dataset_name is required when write_to_txt is true")
 	}
+	for i, f := range c.Filters {
+		if f.Key == "" && f.Name == "" {
+			return fmt.Errorf("filters[%d]: key or name is required", i)
+		}
+	}
+	if err := validatePipeline(c.Pipeline); err != nil {
+		return err
+	}
+	if _, err := models.CompileRules(c.FilterRules); err != nil {
+		return fmt.Errorf("filter_rules: %w", err)
+	}
+	if c.Extractor != "" && !knownMode(c.Extractor) {
+		return fmt.Errorf("unknown extractor %q", c.Extractor)
+	}
+	for i := range c.Sources {
+		if err := c.Sources[i].validate(c.Extractor); err != nil {
+			return fmt.Errorf("sources[%d]: %w", i, err)
+		}
+	}
 	return nil
 }
 
 func (c *Config) Save(path string) error {
+	c.migrate()
 	c.applyDefaults()
 	c.sortFilters()
 
@@ -131,7 +248,11 @@ func (c *Config) Save(path string) error {
 
 	c.warnEmptyValues()
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	sealed, err := c.sealedCopy(path)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sealed, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
@@ -144,7 +265,7 @@ func (c *Config) Save(path string) error {
 }
 
 func (c *Config) warnEmptyValues() {
-	if len(c.Links) == 0 {
+	if len(c.ActiveSources()) == 0 {
 		slog.Warn("no URLs configured")
 	}
 	for i, line := range c.AddLines {
@@ -157,6 +278,37 @@ func (c *Config) warnEmptyValues() {
 	}
 }
 
+// migrate converts deprecated fields into their current equivalents.
+func (c *Config) migrate() {
+	if c.WithEq != nil {
+		if c.Extractor == "" {
+			c.Extractor = ModeTable
+			if *c.WithEq {
+				c.Extractor = ModeEquals
+			}
+		}
+		slog.Info("migrated with_eq to extractor", "extractor", c.Extractor)
+		c.WithEq = nil
+	} else if len(c.Links) > 0 && c.Extractor == "" {
+		// Legacy configs without with_eq scraped tables
+		c.Extractor = ModeTable
+	}
+	if len(c.Links) > 0 {
+		for _, link := range c.Links {
+			c.Sources = append(c.Sources, Source{
+				URL:     link,
+				Enabled: true,
+			})
+		}
+		slog.Info("migrated links to sources", "count", len(c.Links))
+	}
+	c.Links = nil
+	if len(c.FilterLines) > 0 && len(c.Filters) > 0 {
+		slog.Info("dropped filter_lines in favour of filters", "count", len(c.FilterLines))
+		c.FilterLines = nil
+	}
+}
+
 func (c *Config) applyDefaults() {
 	if c.IP == "" {
 		c.IP = "localhost"
@@ -164,6 +316,55 @@ func (c *Config) applyDefaults() {
 	if c.UpdateInterval == 0 {
 		c.UpdateInterval = defaultUpdateInterval
 	}
+	if c.MaxConcurrency == 0 {
+		c.MaxConcurrency = defaultMaxConcurrency
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = defaultMaxIdleConns
+	}
+	if c.IdleConnTimeout == 0 {
+		c.IdleConnTimeout = defaultIdleTimeout
+	}
+	if c.TLSMinVersion == "" {
+		c.TLSMinVersion = defaultTLSMinVersion
+	}
+	if c.Extractor == "" {
+		c.Extractor = defaultExtractor
+	}
+	if c.SumDecimalSeparator == "" {
+		c.SumDecimalSeparator = "."
+	}
+	if c.SumSymbolPosition == "" {
+		c.SumSymbolPosition = SymbolSuffix
+	}
+	if c.Sources == nil {
+		c.Sources = []Source{}
+	}
+	if c.Filters == nil {
+		c.Filters = []models.LineFilter{}
+	}
+	if len(c.Pipeline) == 0 {
+		c.Pipeline = DefaultPipeline()
+	}
+	if c.FilterRules == nil {
+		c.FilterRules = []models.FilterRule{}
+	}
+	if c.NoProxy == nil {
+		c.NoProxy = []string{}
+	}
+	for i := range c.Sources {
+		if c.Sources[i].Login != nil {
+			c.Sources[i].Login.applyDefaults()
+		}
+	}
+}
+
+// TLSVersion returns the minimum TLS version as a crypto/tls constant.
+func (c *Config) TLSVersion() uint16 {
+	if v, ok := tlsVersions[c.TLSMinVersion]; ok {
+		return v
+	}
+	return tls.VersionTLS12
 }
 
 func (c *Config) sortFilters() {
@@ -181,3 +382,85 @@ func (c *Config) FilterLinesZeroIndexed() []int {
 	}
 	return result
 }
+
+// HasFilters reports whether any line filter or filter rule is configured.
+func (c *Config) HasFilters() bool {
+	return len(c.Filters) > 0 || len(c.FilterLines) > 0 || len(c.FilterRules) > 0
+}
+
+// FilterData returns the lines of data selected by the filters and kept by
+// rules, the compiled filter rules.
+func (c *Config) FilterData(data []models.Data, rules *models.RuleSet) []models.Data {
+	kept, _ := c.ExplainFilters(data, rules)
+	return kept
+}
+
+// ExplainFilters filters data like FilterData and also reports, for every
+// line of data, whether it was kept and which filter or rule decided. The
+// filters select lines first, using the legacy filter_lines positions while
+// they are not yet migrated, and rules, compiled from FilterRules, are then
+// evaluated in order on the selected lines.
+func (c *Config) ExplainFilters(data []models.Data, rules *models.RuleSet) ([]models.Data, []models.LineDecision) {
+	decisions := make([]models.LineDecision, len(data))
+	var selected []int
+	switch {
+	case len(c.Filters) > 0:
+		selected = models.SelectLines(c.Filters, data)
+	case len(c.FilterLines) > 0:
+		for _, l := range c.FilterLinesZeroIndexed() {
+			if l >= 0 && l < len(data) {
+				selected = append(selected, l)
+			}
+		}
+	default:
+		selected = make([]int, len(data))
+		for i := range data {
+			selected[i] = i
+		}
+	}
+	for i := range decisions {
+		decisions[i] = models.LineDecision{Rule: -1, Reason: "dropped, not selected by filters"}
+	}
+
+	var kept []models.Data
+	for _, i := range selected {
+		decisions[i] = rules.Decide(data[i])
+		if decisions[i].Kept {
+			kept = append(kept, data[i])
+		}
+	}
+	return kept, decisions
+}
+
+// MigrateFilterLines replaces the legacy filter_lines positions with key
+// filters resolved against rawData, the lines of a scrape in which every
+// source succeeded. It reports whether there was anything to migrate.
+func (c *Config) MigrateFilterLines(rawData []models.Data) bool {
+	if len(c.FilterLines) == 0 {
+		return false
+	}
+	c.Filters = models.FiltersFromIndices(c.FilterLinesZeroIndexed(), rawData)
+	c.FilterLines = nil
+	return true
+}
+
+func validatePipeline(stages []string) error {
+	seen := make(map[string]bool, len(stages))
+	for i, stage := range stages {
+		if stage == "" {
+			return fmt.Errorf("pipeline[%d]: stage name is required", i)
+		}
+		if !knownStage(stage) {
+			return fmt.Errorf("pipeline[%d]: unknown stage %q", i, stage)
+		}
+		if seen[stage] {
+			return fmt.Errorf("pipeline[%d]: duplicate stage %q", i, stage)
+		}
+		// Filter decisions are reported against the raw lines
+		if stage == StageFilter && i > 0 {
+			return fmt.Errorf("pipeline[%d]: %q must be the first stage", i, stage)
+		}
+		seen[stage] = true
+	}
+	return nil
+}
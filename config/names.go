@@ -0,0 +1,53 @@
+package config
+
+import "sync"
+
+// Extractor modes and pipeline stages can be added by the scraper and
+// pipeline packages, so the config keeps the sets of valid names it
+// validates against.
+var (
+	namesMu sync.RWMutex
+	modes   = map[string]bool{
+		ModeEquals: true,
+		ModeTable:  true,
+		ModeCSS:    true,
+		ModeXPath:  true,
+		ModeJSON:   true,
+		ModeRegex:  true,
+	}
+	stages = map[string]bool{
+		StageFilter:  true,
+		StageAdd:     true,
+		StageCompute: true,
+		StageSum:     true,
+		StageFormat:  true,
+	}
+)
+
+// RegisterMode makes mode a valid extractor mode. scraper.Register calls it
+// for every extractor it adds.
+func RegisterMode(mode string) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	modes[mode] = true
+}
+
+// RegisterStage makes name a valid pipeline stage. pipeline.Register calls
+// it for every stage it adds.
+func RegisterStage(name string) {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	stages[name] = true
+}
+
+func knownMode(mode string) bool {
+	namesMu.RLock()
+	defer namesMu.RUnlock()
+	return modes[mode]
+}
+
+func knownStage(name string) bool {
+	namesMu.RLock()
+	defer namesMu.RUnlock()
+	return stages[name]
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Proxy is an outbound proxy used for scraping. Username and Password are
+// encrypted when the config is saved.
+type Proxy struct {
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (p *Proxy) validate() error {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("url scheme must be http, https or socks5")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("url must include a host")
+	}
+	if u.User != nil {
+		return fmt.Errorf("set username and password instead of putting credentials in the url")
+	}
+	return nil
+}
+
+// Address returns the proxy URL with its credentials, or an empty string
+// when p is nil.
+func (p *Proxy) Address() string {
+	if p == nil {
+		return ""
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return ""
+	}
+	if p.Username != "" {
+		u.User = url.UserPassword(p.Username, p.Password)
+	}
+	return u.String()
+}
+
+func validateNoProxy(hosts []string) error {
+	for i, h := range hosts {
+		if h == "" || strings.ContainsAny(h, " ,") {
+			return fmt.Errorf("no_proxy[%d]: %q is not a host, domain or CIDR", i, h)
+		}
+	}
+	return nil
+}
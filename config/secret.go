@@ -0,0 +1,182 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/batijo/poll-scraper/utils"
+)
+
+const (
+	secretPrefix  = "enc:"
+	secretKeyFile = "secret.key"
+	secretKeySize = 32
+)
+
+// secretKeyPath returns the path of the key used to encrypt the credentials
+// of the config at configPath. It is kept next to the config file.
+func secretKeyPath(configPath string) string {
+	return filepath.Join(filepath.Dir(filepath.Clean(configPath)), secretKeyFile)
+}
+
+// loadSecretKey reads the encryption key, generating it first when create is
+// set and it does not exist yet.
+func loadSecretKey(path string, create bool) ([]byte, error) {
+	key, err := os.ReadFile(filepath.Clean(path))
+	if err == nil {
+		if len(key) != secretKeySize {
+			return nil, fmt.Errorf("invalid secret key in %s", path)
+		}
+		return key, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) || !create {
+		return nil, fmt.Errorf("failed to read secret key: %w", err)
+	}
+	key = make([]byte, secretKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate secret key: %w", err)
+	}
+	if err := os.WriteFile(filepath.Clean(path), key, utils.FileMode); err != nil {
+		return nil, fmt.Errorf("failed to write secret key: %w", err)
+	}
+	slog.Info("generated secret key", "path", path)
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealSecret(key []byte, plain string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func openSecret(key []byte, value string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return "", fmt.Errorf("encrypted value too short")
+	}
+	plain, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func isSealed(value string) bool {
+	return strings.HasPrefix(value, secretPrefix)
+}
+
+// secrets returns pointers to every credential stored in c.
+func (c *Config) secrets() []*string {
+	var fields []*string
+	if c.Proxy != nil {
+		fields = append(fields, &c.Proxy.Username, &c.Proxy.Password)
+	}
+	for i := range c.Sources {
+		if l := c.Sources[i].Login; l != nil {
+			fields = append(fields, &l.Username, &l.Password)
+		}
+		if p := c.Sources[i].Proxy; p != nil {
+			fields = append(fields, &p.Username, &p.Password)
+		}
+	}
+	return fields
+}
+
+// openSecrets decrypts the credentials read from the config at path.
+// Credentials written by hand in plain text are kept as they are and
+// encrypted on the next save.
+func (c *Config) openSecrets(path string) error {
+	var key []byte
+	warned := false
+	for _, field := range c.secrets() {
+		if !isSealed(*field) {
+			if *field != "" && !warned {
+				slog.Warn("plain-text credentials in config, they will be encrypted on save")
+				warned = true
+			}
+			continue
+		}
+		if key == nil {
+			var err error
+			if key, err = loadSecretKey(secretKeyPath(path), false); err != nil {
+				return err
+			}
+		}
+		plain, err := openSecret(key, *field)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt credentials: %w", err)
+		}
+		*field = plain
+	}
+	return nil
+}
+
+// sealedCopy returns a copy of c with its credentials encrypted, ready to be
+// written to the config at path.
+func (c *Config) sealedCopy(path string) (*Config, error) {
+	sealed := *c
+	if c.Proxy != nil {
+		p := *c.Proxy
+		sealed.Proxy = &p
+	}
+	sealed.Sources = make([]Source, len(c.Sources))
+	for i, src := range c.Sources {
+		if src.Login != nil {
+			l := *src.Login
+			src.Login = &l
+		}
+		if src.Proxy != nil {
+			p := *src.Proxy
+			src.Proxy = &p
+		}
+		sealed.Sources[i] = src
+	}
+	var key []byte
+	for _, field := range sealed.secrets() {
+		if *field == "" {
+			continue
+		}
+		if key == nil {
+			var err error
+			if key, err = loadSecretKey(secretKeyPath(path), true); err != nil {
+				return nil, err
+			}
+		}
+		v, err := sealSecret(key, *field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt credentials: %w", err)
+		}
+		*field = v
+	}
+	return &sealed, nil
+}
@@ -0,0 +1,289 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/net/http/httpguts"
+
+	"github.com/batijo/poll-scraper/utils/jsonpath"
+)
+
+const (
+	maxRetries           = 10
+	defaultUsernameField = "username"
+	defaultPasswordField = "password"
+	idLength             = 8
+)
+
+// Source is a single scraped URL with its own extraction settings.
+type Source struct {
+	URL              string            `json:"url"`
+	Label            string            `json:"label"`
+	Mode             string            `json:"mode"`
+	Charset          string            `json:"charset"`
+	DecimalSeparator string            `json:"decimal_separator"`
+	Enabled          bool              `json:"enabled"`
+	Timeout          int               `json:"timeout"`
+	Retries          int               `json:"retries"`
+	RetryBackoff     int               `json:"retry_backoff"`
+	HoldLastGood     bool              `json:"hold_last_good"`
+	MaxStaleness     int               `json:"max_staleness"`
+	UserAgent        string            `json:"user_agent"`
+	Headers          map[string]string `json:"headers"`
+	Cookies          []Cookie          `json:"cookies"`
+	Login            *Login            `json:"login,omitempty"`
+	Proxy            *Proxy            `json:"proxy,omitempty"`
+	ExpectedLines    int               `json:"expected_lines"`
+	Selectors        Selectors         `json:"selectors"`
+}
+
+// Cookie is a cookie sent with every request to a source.
+type Cookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Login is a form login run before scraping a protected source. Username
+// and Password are encrypted when the config is saved.
+type Login struct {
+	URL           string            `json:"url"`
+	UsernameField string            `json:"username_field"`
+	PasswordField string            `json:"password_field"`
+	Username      string            `json:"username"`
+	Password      string            `json:"password"`
+	Fields        map[string]string `json:"fields"`
+}
+
+// Selectors overrides the elements an extractor reads. They are CSS
+// selectors, XPath expressions in xpath mode or JSONPath-style paths in
+// json mode. Name and Value are
+// evaluated relative to each Row match; an empty Name or Value selects the
+// row itself. NameAttr and ValueAttr read an attribute instead of the text.
+// Pattern is the regular expression used in regex mode.
+type Selectors struct {
+	Row       string `json:"row"`
+	Cell      string `json:"cell"`
+	Name      string `json:"name"`
+	Value     string `json:"value"`
+	NameAttr  string `json:"name_attr"`
+	ValueAttr string `json:"value_attr"`
+	Pattern   string `json:"pattern"`
+}
+
+// ID identifies what is scraped from the source: sources with the same ID
+// request the same page and extract the same lines from it, so they can
+// share sessions, cached responses and held data.
+func (s *Source) ID() string {
+	b, _ := json.Marshal(struct { //nolint:errcheck // plain strings and maps always encode
+		URL, Mode, Charset, DecimalSeparator, UserAgent string
+		Headers                                         map[string]string
+		Cookies                                         []Cookie
+		Login                                           *Login
+		Selectors                                       Selectors
+	}{s.URL, s.Mode, s.Charset, s.DecimalSeparator, s.UserAgent, s.Headers, s.Cookies, s.Login, s.Selectors})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:idLength])
+}
+
+// Name returns the label of the source, or its URL when no label is set.
+func (s *Source) Name() string {
+	if s.Label != "" {
+		return s.Label
+	}
+	return s.URL
+}
+
+func (s *Source) validate(defaultMode string) error {
+	if s.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url scheme must be http or https")
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	if s.Retries < 0 || s.Retries > maxRetries {
+		return fmt.Errorf("retries must be between 0 and %d", maxRetries)
+	}
+	if s.RetryBackoff < 0 {
+		return fmt.Errorf("retry_backoff cannot be negative")
+	}
+	if s.MaxStaleness < 0 {
+		return fmt.Errorf("max_staleness cannot be negative")
+	}
+	if s.ExpectedLines < 0 {
+		return fmt.Errorf("expected_lines cannot be negative")
+	}
+	for name, value := range s.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("invalid header %q", name)
+		}
+	}
+	if !httpguts.ValidHeaderFieldValue(s.UserAgent) {
+		return fmt.Errorf("invalid user_agent")
+	}
+	for i, c := range s.Cookies {
+		if !httpguts.ValidHeaderFieldName(c.Name) || strings.ContainsAny(c.Value, ";\r\n") {
+			return fmt.Errorf("invalid cookies[%d] %q", i, c.Name)
+		}
+	}
+	if s.DecimalSeparator != "" && s.DecimalSeparator != "." && s.DecimalSeparator != "," {
+		return fmt.Errorf("decimal_separator must be \".\" or \",\"")
+	}
+	if s.Charset != "" {
+		if enc, _ := charset.Lookup(s.Charset); enc == nil {
+			return fmt.Errorf("unknown charset %q", s.Charset)
+		}
+	}
+	if s.Login != nil {
+		if err := s.Login.validate(); err != nil {
+			return fmt.Errorf("login: %w", err)
+		}
+	}
+	if s.Proxy != nil {
+		if err := s.Proxy.validate(); err != nil {
+			return fmt.Errorf("proxy: %w", err)
+		}
+	}
+	mode := s.Mode
+	if mode == "" {
+		mode = defaultMode
+	}
+	if mode != "" && !knownMode(mode) {
+		return fmt.Errorf("unknown mode %q", mode)
+	}
+	return s.validateSelectors(mode)
+}
+
+func (l *Login) validate() error {
+	u, err := url.Parse(l.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	return nil
+}
+
+func (l *Login) applyDefaults() {
+	if l.UsernameField == "" {
+		l.UsernameField = defaultUsernameField
+	}
+	if l.PasswordField == "" {
+		l.PasswordField = defaultPasswordField
+	}
+}
+
+func (s *Source) validateSelectors(mode string) error {
+	var compile func(string) error
+	switch mode {
+	case ModeTable, ModeEquals, "":
+		compile = compileCSS
+	case ModeCSS:
+		if s.Selectors.Row == "" {
+			return fmt.Errorf("selectors.row is required in %s mode", mode)
+		}
+		compile = compileCSS
+	case ModeXPath:
+		compile = compileXPath
+	case ModeJSON:
+		compile = compileJSONPath
+	case ModeRegex:
+		if err := validatePattern(s.Selectors.Pattern); err != nil {
+			return err
+		}
+		compile = compileCSS
+	default:
+		// Registered extractors validate their own selectors
+		return nil
+	}
+	if (mode == ModeXPath || mode == ModeJSON) && (s.Selectors.Name == "" || s.Selectors.Value == "") {
+		return fmt.Errorf("selectors.name and selectors.value are required in %s mode", mode)
+	}
+	fields := []struct{ name, sel string }{
+		{"row", s.Selectors.Row},
+		{"cell", s.Selectors.Cell},
+		{"name", s.Selectors.Name},
+		{"value", s.Selectors.Value},
+	}
+	for _, f := range fields {
+		if f.sel == "" {
+			continue
+		}
+		if err := compile(f.sel); err != nil {
+			return fmt.Errorf("invalid selectors.%s %q: %w", f.name, f.sel, err)
+		}
+	}
+	return nil
+}
+
+func compileCSS(sel string) error {
+	_, err := cascadia.Compile(sel)
+	return err
+}
+
+func compileXPath(expr string) error {
+	_, err := xpath.Compile(expr)
+	return err
+}
+
+func validatePattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("selectors.pattern is required in %s mode", ModeRegex)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid selectors.pattern %q: %w", pattern, err)
+	}
+	if re.SubexpIndex("name") < 0 || re.SubexpIndex("value") < 0 {
+		return fmt.Errorf("selectors.pattern must contain (?P<name>...) and (?P<value>...) groups")
+	}
+	return nil
+}
+
+func compileJSONPath(expr string) error {
+	_, err := jsonpath.Compile(expr)
+	return err
+}
+
+// ActiveSources returns the enabled sources with the global extractor
+// applied to any source that does not set its own mode.
+func (c *Config) ActiveSources() []Source {
+	sources := make([]Source, 0, len(c.Sources))
+	for _, s := range c.Sources {
+		if !s.Enabled {
+			continue
+		}
+		if s.Mode == "" {
+			s.Mode = c.Extractor
+		}
+		sources = append(sources, s)
+	}
+	return sources
+}
+
+// SourceByURL returns the configured source for link. When link is not
+// configured, an enabled source using the global extractor is returned.
+func (c *Config) SourceByURL(link string) Source {
+	for _, s := range c.Sources {
+		if s.URL == link {
+			if s.Mode == "" {
+				s.Mode = c.Extractor
+			}
+			return s
+		}
+	}
+	return Source{URL: link, Mode: c.Extractor, Enabled: true}
+}
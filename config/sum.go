@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/batijo/poll-scraper/models"
+)
+
+const maxSumDecimals = 10
+
+// Positions of the sum symbol relative to the number.
+const (
+	SymbolPrefix = "prefix"
+	SymbolSuffix = "suffix"
+)
+
+var thousandsSeparators = []string{"", " ", "\u00a0", ",", ".", "'"}
+
+func (c *Config) validateSum() error {
+	if c.SumDecimals != nil && (*c.SumDecimals < 0 || *c.SumDecimals > maxSumDecimals) {
+		return fmt.Errorf("sum_decimals must be between 0 and %d", maxSumDecimals)
+	}
+	if c.SumDecimalSeparator != "" && c.SumDecimalSeparator != "." && c.SumDecimalSeparator != "," {
+		return fmt.Errorf("sum_decimal_separator must be \".\" or \",\"")
+	}
+	if !slices.Contains(thousandsSeparators, c.SumThousandsSeparator) {
+		return fmt.Errorf("sum_thousands_separator must be empty, a space, \",\", \".\" or \"'\"")
+	}
+	decimal := c.SumDecimalSeparator
+	if decimal == "" {
+		decimal = "."
+	}
+	if c.SumThousandsSeparator == decimal {
+		return fmt.Errorf("sum_thousands_separator must differ from sum_decimal_separator")
+	}
+	if c.SumSymbolPosition != "" && c.SumSymbolPosition != SymbolPrefix && c.SumSymbolPosition != SymbolSuffix {
+		return fmt.Errorf("sum_symbol_position must be %q or %q", SymbolPrefix, SymbolSuffix)
+	}
+	return nil
+}
+
+// SumFormat returns the formatting applied to the sum lines.
+func (c *Config) SumFormat() models.SumFormat {
+	decimals := models.AutoDecimals
+	if c.SumDecimals != nil {
+		decimals = *c.SumDecimals
+	}
+	return models.SumFormat{
+		Decimals:           decimals,
+		DecimalSeparator:   c.SumDecimalSeparator,
+		ThousandsSeparator: c.SumThousandsSeparator,
+		Symbol:             c.SumSymbols,
+		SymbolPrefix:       c.SumSymbolPosition == SymbolPrefix,
+	}
+}
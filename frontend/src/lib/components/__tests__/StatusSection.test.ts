@@ -1,12 +1,12 @@
 import { render, screen } from '@testing-library/svelte';
 import { expect, test } from 'vitest';
 import StatusSection from '../StatusSection.svelte';
-import { createDefaultConfig } from '$lib/types/config';
+import { createDefaultConfig, createSource } from '$lib/types/config';
 
 function renderStatus(propOverrides: Record<string, unknown> = {}) {
   const config = {
     ...createDefaultConfig(),
-    links: ['http://example.com'],
+    sources: [createSource('http://example.com')],
   };
   return render(StatusSection, { config, ...propOverrides });
 }
@@ -29,8 +29,8 @@ test('shows URL ok/fail breakdown', () => {
   renderStatus({
     scraperState: 'scraping',
     urlStatusList: [
-      { url: 'http://ok.com', hasData: true, lineCount: 3, error: false },
-      { url: 'http://bad.com', hasData: false, lineCount: 0, error: true },
+      { url: 'http://ok.com', label: '', hasData: true, lineCount: 3, error: false },
+      { url: 'http://bad.com', label: '', hasData: false, lineCount: 0, error: true },
     ],
   });
   expect(screen.getByText('ok')).toBeInTheDocument();
@@ -41,7 +41,7 @@ test('does not show fail count when all URLs are healthy', () => {
   renderStatus({
     scraperState: 'scraping',
     urlStatusList: [
-      { url: 'http://ok.com', hasData: true, lineCount: 3, error: false },
+      { url: 'http://ok.com', label: '', hasData: true, lineCount: 3, error: false },
     ],
   });
   expect(screen.getByText('ok')).toBeInTheDocument();
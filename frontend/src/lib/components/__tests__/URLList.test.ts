@@ -1,6 +1,7 @@
 import { render } from '@testing-library/svelte';
 import { expect, test, vi } from 'vitest';
 import URLList from '../forms/URLList.svelte';
+import { createSource } from '../../types/config';
 
 vi.mock('../../../../wailsjs/go/main/App', () => ({
   PreviewURL: vi.fn().mockResolvedValue([]),
@@ -8,9 +9,9 @@ vi.mock('../../../../wailsjs/go/main/App', () => ({
 
 test('shows red dot with "Line count changed" title when URL has error', () => {
   const { container } = render(URLList, {
-    links: ['http://example.com'],
-    initialLinks: ['http://example.com'],
-    urlStatusList: [{ url: 'http://example.com', hasData: true, lineCount: 5, error: true }],
+    sources: [createSource('http://example.com')],
+    initialSources: [createSource('http://example.com')],
+    urlStatusList: [{ url: 'http://example.com', label: '', hasData: true, lineCount: 5, error: true }],
   });
 
   const dot = container.querySelector('.bg-red-500');
@@ -20,9 +21,9 @@ test('shows red dot with "Line count changed" title when URL has error', () => {
 
 test('shows green dot when URL has data and no error', () => {
   const { container } = render(URLList, {
-    links: ['http://example.com'],
-    initialLinks: ['http://example.com'],
-    urlStatusList: [{ url: 'http://example.com', hasData: true, lineCount: 5, error: false }],
+    sources: [createSource('http://example.com')],
+    initialSources: [createSource('http://example.com')],
+    urlStatusList: [{ url: 'http://example.com', label: '', hasData: true, lineCount: 5, error: false }],
   });
 
   const dot = container.querySelector('.bg-green-500');
@@ -32,8 +33,8 @@ test('shows green dot when URL has data and no error', () => {
 
 test('shows gray dot when no status available', () => {
   const { container } = render(URLList, {
-    links: ['http://example.com'],
-    initialLinks: ['http://example.com'],
+    sources: [createSource('http://example.com')],
+    initialSources: [createSource('http://example.com')],
     urlStatusList: [],
   });
 
@@ -44,11 +45,11 @@ test('shows gray dot when no status available', () => {
 
 test('shows red dot only for the URL with error', () => {
   const { container } = render(URLList, {
-    links: ['http://ok.com', 'http://bad.com'],
-    initialLinks: ['http://ok.com', 'http://bad.com'],
+    sources: [createSource('http://ok.com'), createSource('http://bad.com')],
+    initialSources: [createSource('http://ok.com'), createSource('http://bad.com')],
     urlStatusList: [
-      { url: 'http://ok.com', hasData: true, lineCount: 3, error: false },
-      { url: 'http://bad.com', hasData: true, lineCount: 5, error: true },
+      { url: 'http://ok.com', label: '', hasData: true, lineCount: 3, error: false },
+      { url: 'http://bad.com', label: '', hasData: true, lineCount: 5, error: true },
     ],
   });
 
@@ -1,21 +1,123 @@
+import type { ScraperData } from './scraper';
+
+export interface LineFilter {
+  key?: string;
+  source?: string;
+  name?: string;
+}
+
+export interface FilterRule {
+  action: 'include' | 'exclude';
+  source?: string;
+  name?: string;
+  value?: string;
+}
+
 export interface CustomLine {
   name: string;
   value: string;
   filtered: boolean;
 }
 
+export interface Cookie {
+  name: string;
+  value: string;
+}
+
+export interface Login {
+  url: string;
+  username_field: string;
+  password_field: string;
+  username: string;
+  password: string;
+  fields: Record<string, string>;
+}
+
+export interface Proxy {
+  url: string;
+  username: string;
+  password: string;
+}
+
+export interface Selectors {
+  row: string;
+  cell: string;
+  name: string;
+  value: string;
+  name_attr: string;
+  value_attr: string;
+  pattern: string;
+}
+
+export interface Source {
+  url: string;
+  label: string;
+  mode: string;
+  charset: string;
+  decimal_separator: string;
+  enabled: boolean;
+  timeout: number;
+  retries: number;
+  retry_backoff: number;
+  hold_last_good: boolean;
+  max_staleness: number;
+  user_agent: string;
+  headers: Record<string, string>;
+  cookies: Cookie[];
+  login?: Login;
+  proxy?: Proxy;
+  expected_lines: number;
+  selectors: Selectors;
+}
+
+export function createSource(url: string): Source {
+  return {
+    url,
+    label: '',
+    mode: '',
+    charset: '',
+    decimal_separator: '',
+    enabled: true,
+    timeout: 0,
+    retries: 0,
+    retry_backoff: 0,
+    hold_last_good: false,
+    max_staleness: 0,
+    user_agent: '',
+    headers: {},
+    cookies: [],
+    expected_lines: 0,
+    selectors: { row: '', cell: '', name: '', value: '', name_attr: '', value_attr: '', pattern: '' },
+  };
+}
+
 export interface Config {
-  links: string[];
+  sources: Source[];
   port: number;
   ip: string;
   domains: string[];
   enable_server: boolean;
-  with_eq: boolean;
-  filter_lines: number[];
+  extractor: string;
+  filters: LineFilter[];
+  filter_rules: FilterRule[];
+  pipeline: string[];
+  filter_lines?: number[];
   add_lines: CustomLine[];
   add_sum: boolean;
   sum_symbols: string;
+  sum_decimals?: number;
+  sum_decimal_separator: string;
+  sum_thousands_separator: string;
+  sum_symbol_position: string;
   update_interval: number;
+  max_concurrency: number;
+  user_agent: string;
+  max_idle_conns: number;
+  idle_conn_timeout: number;
+  tls_min_version: string;
+  tls_skip_verify: boolean;
+  proxy?: Proxy;
+  no_proxy: string[];
   write_to_csv: boolean;
   csv_path: string;
   write_to_txt: boolean;
@@ -28,17 +130,29 @@ export interface Config {
 
 export function createDefaultConfig(): Config {
   return {
-    links: [],
+    sources: [],
     port: 3000,
     ip: 'localhost',
     domains: [],
     enable_server: true,
-    with_eq: false,
-    filter_lines: [],
+    extractor: 'table',
+    filters: [],
+    filter_rules: [],
+    pipeline: ['filter', 'add', 'compute', 'sum'],
     add_lines: [],
     add_sum: false,
     sum_symbols: '',
+    sum_decimal_separator: '.',
+    sum_thousands_separator: '',
+    sum_symbol_position: 'suffix',
     update_interval: 1000,
+    max_concurrency: 4,
+    user_agent: '',
+    max_idle_conns: 16,
+    idle_conn_timeout: 90000,
+    tls_min_version: '1.2',
+    tls_skip_verify: false,
+    no_proxy: [],
     write_to_csv: false,
     csv_path: '',
     write_to_txt: false,
@@ -49,3 +163,23 @@ export function createDefaultConfig(): Config {
     stop_on_line_count_change: false,
   };
 }
+
+export function filterMatches(filter: LineFilter, line: ScraperData): boolean {
+  if (filter.key) {
+    return line.key === filter.key;
+  }
+  return line.name === filter.name && (!filter.source || line.source === filter.source);
+}
+
+// selectedKeys returns the keys of the lines in rawData picked by the config's
+// filters, resolving legacy filter_lines positions until they are migrated.
+export function selectedKeys(config: Config, rawData: ScraperData[]): string[] {
+  if (config.filters && config.filters.length > 0) {
+    return rawData
+      .filter((line) => line.key && config.filters.some((f) => filterMatches(f, line)))
+      .map((line) => line.key as string);
+  }
+  return (config.filter_lines ?? [])
+    .map((idx) => rawData[idx - 1]?.key)
+    .filter((key): key is string => !!key);
+}
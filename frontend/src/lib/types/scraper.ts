@@ -1,6 +1,9 @@
 export interface ScraperData {
   name: string;
   value: string;
+  number?: string;
+  source?: string;
+  key?: string;
 }
 
 export type ScraperState = 'idle' | 'scraping' | 'error' | 'stopped';
@@ -24,13 +27,32 @@ export interface LogEntry {
 
 export interface URLStatus {
   url: string;
+  label: string;
   hasData: boolean;
   lineCount: number;
   error: boolean;
+  durationMs: number;
+  statusCode: number;
+  bytes: number;
+  charset: string;
+  attempts: number;
+  stale: boolean;
+  staleMs: number;
+  notModified: boolean;
+  cacheHitRate: number;
+  errorKind: string;
+  message: string;
+}
+
+export interface LineDecision {
+  kept: boolean;
+  rule: number;
+  reason: string;
 }
 
 export interface PreviewResult {
   rawData: ScraperData[];
   data: ScraperData[];
+  decisions: LineDecision[];
   statuses: URLStatus[];
 }
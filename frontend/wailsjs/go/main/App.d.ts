@@ -18,6 +18,8 @@ export function GetConfig():Promise<config.Config>;
 
 export function IsScraperRunning():Promise<boolean>;
 
+export function MigrateFilterLines(arg1:Array<models.Data>):Promise<void>;
+
 export function PreviewScrape():Promise<models.PreviewResult>;
 
 export function PreviewURL(arg1:string):Promise<Array<models.Data>>;
@@ -30,6 +30,10 @@ export function IsScraperRunning() {
   return window['go']['main']['App']['IsScraperRunning']();
 }
 
+export function MigrateFilterLines(arg1) {
+  return window['go']['main']['App']['MigrateFilterLines'](arg1);
+}
+
 export function PreviewScrape() {
   return window['go']['main']['App']['PreviewScrape']();
 }
@@ -16,18 +16,172 @@ export namespace config {
 	        this.filtered = source["filtered"];
 	    }
 	}
+	export class Selectors {
+	    row: string;
+	    cell: string;
+	    name: string;
+	    value: string;
+	    name_attr: string;
+	    value_attr: string;
+	    pattern: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Selectors(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.row = source["row"];
+	        this.cell = source["cell"];
+	        this.name = source["name"];
+	        this.value = source["value"];
+	        this.name_attr = source["name_attr"];
+	        this.value_attr = source["value_attr"];
+	        this.pattern = source["pattern"];
+	    }
+	}
+	export class Proxy {
+	    url: string;
+	    username: string;
+	    password: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Proxy(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.username = source["username"];
+	        this.password = source["password"];
+	    }
+	}
+	export class Login {
+	    url: string;
+	    username_field: string;
+	    password_field: string;
+	    username: string;
+	    password: string;
+	    fields: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new Login(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.username_field = source["username_field"];
+	        this.password_field = source["password_field"];
+	        this.username = source["username"];
+	        this.password = source["password"];
+	        this.fields = source["fields"];
+	    }
+	}
+	export class Cookie {
+	    name: string;
+	    value: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Cookie(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.value = source["value"];
+	    }
+	}
+	export class Source {
+	    url: string;
+	    label: string;
+	    mode: string;
+	    charset: string;
+	    decimal_separator: string;
+	    enabled: boolean;
+	    timeout: number;
+	    retries: number;
+	    retry_backoff: number;
+	    hold_last_good: boolean;
+	    max_staleness: number;
+	    user_agent: string;
+	    headers: Record<string, string>;
+	    cookies: Cookie[];
+	    login?: Login;
+	    proxy?: Proxy;
+	    expected_lines: number;
+	    selectors: Selectors;
+	
+	    static createFrom(source: any = {}) {
+	        return new Source(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.url = source["url"];
+	        this.label = source["label"];
+	        this.mode = source["mode"];
+	        this.charset = source["charset"];
+	        this.decimal_separator = source["decimal_separator"];
+	        this.enabled = source["enabled"];
+	        this.timeout = source["timeout"];
+	        this.retries = source["retries"];
+	        this.retry_backoff = source["retry_backoff"];
+	        this.hold_last_good = source["hold_last_good"];
+	        this.max_staleness = source["max_staleness"];
+	        this.user_agent = source["user_agent"];
+	        this.headers = source["headers"];
+	        this.cookies = this.convertValues(source["cookies"], Cookie);
+	        this.login = this.convertValues(source["login"], Login);
+	        this.proxy = this.convertValues(source["proxy"], Proxy);
+	        this.expected_lines = source["expected_lines"];
+	        this.selectors = this.convertValues(source["selectors"], Selectors);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class Config {
-	    links: string[];
+	    sources: Source[];
 	    port: number;
 	    ip: string;
 	    domains: string[];
 	    enable_server: boolean;
-	    with_eq: boolean;
-	    filter_lines: number[];
+	    extractor: string;
+	    filters: models.LineFilter[];
+	    filter_rules: models.FilterRule[];
 	    add_lines: AddLine[];
+	    pipeline: string[];
 	    add_sum: boolean;
 	    sum_symbols: string;
+	    sum_decimals?: number;
+	    sum_decimal_separator: string;
+	    sum_thousands_separator: string;
+	    sum_symbol_position: string;
 	    update_interval: number;
+	    max_concurrency: number;
+	    user_agent: string;
+	    max_idle_conns: number;
+	    idle_conn_timeout: number;
+	    tls_min_version: string;
+	    tls_skip_verify: boolean;
+	    proxy?: Proxy;
+	    no_proxy: string[];
 	    write_to_csv: boolean;
 	    csv_path: string;
 	    write_to_txt: boolean;
@@ -36,6 +190,9 @@ export namespace config {
 	    dataset_name: string;
 	    debug: boolean;
 	    stop_on_line_count_change: boolean;
+	    with_eq?: boolean;
+	    links?: string[];
+	    filter_lines?: number[];
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -43,17 +200,31 @@ export namespace config {
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
-	        this.links = source["links"];
+	        this.sources = this.convertValues(source["sources"], Source);
 	        this.port = source["port"];
 	        this.ip = source["ip"];
 	        this.domains = source["domains"];
 	        this.enable_server = source["enable_server"];
-	        this.with_eq = source["with_eq"];
-	        this.filter_lines = source["filter_lines"];
+	        this.extractor = source["extractor"];
+	        this.filters = this.convertValues(source["filters"], models.LineFilter);
+	        this.filter_rules = this.convertValues(source["filter_rules"], models.FilterRule);
 	        this.add_lines = this.convertValues(source["add_lines"], AddLine);
+	        this.pipeline = source["pipeline"];
 	        this.add_sum = source["add_sum"];
 	        this.sum_symbols = source["sum_symbols"];
+	        this.sum_decimals = source["sum_decimals"];
+	        this.sum_decimal_separator = source["sum_decimal_separator"];
+	        this.sum_thousands_separator = source["sum_thousands_separator"];
+	        this.sum_symbol_position = source["sum_symbol_position"];
 	        this.update_interval = source["update_interval"];
+	        this.max_concurrency = source["max_concurrency"];
+	        this.user_agent = source["user_agent"];
+	        this.max_idle_conns = source["max_idle_conns"];
+	        this.idle_conn_timeout = source["idle_conn_timeout"];
+	        this.tls_min_version = source["tls_min_version"];
+	        this.tls_skip_verify = source["tls_skip_verify"];
+	        this.proxy = this.convertValues(source["proxy"], Proxy);
+	        this.no_proxy = source["no_proxy"];
 	        this.write_to_csv = source["write_to_csv"];
 	        this.csv_path = source["csv_path"];
 	        this.write_to_txt = source["write_to_txt"];
@@ -62,6 +233,9 @@ export namespace config {
 	        this.dataset_name = source["dataset_name"];
 	        this.debug = source["debug"];
 	        this.stop_on_line_count_change = source["stop_on_line_count_change"];
+	        this.with_eq = source["with_eq"];
+	        this.links = source["links"];
+	        this.filter_lines = source["filter_lines"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -82,6 +256,10 @@ export namespace config {
 		    return a;
 		}
 	}
+	
+	
+	
+	
 
 }
 
@@ -90,6 +268,9 @@ export namespace models {
 	export class Data {
 	    name: string;
 	    value: string;
+	    number?: string;
+	    source?: string;
+	    key?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Data(source);
@@ -99,13 +280,78 @@ export namespace models {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.name = source["name"];
 	        this.value = source["value"];
+	        this.number = source["number"];
+	        this.source = source["source"];
+	        this.key = source["key"];
+	    }
+	}
+	export class FilterRule {
+	    action: string;
+	    source?: string;
+	    name?: string;
+	    value?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FilterRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.action = source["action"];
+	        this.source = source["source"];
+	        this.name = source["name"];
+	        this.value = source["value"];
+	    }
+	}
+	export class LineDecision {
+	    kept: boolean;
+	    rule: number;
+	    reason: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LineDecision(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kept = source["kept"];
+	        this.rule = source["rule"];
+	        this.reason = source["reason"];
+	    }
+	}
+	export class LineFilter {
+	    key?: string;
+	    source?: string;
+	    name?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LineFilter(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.key = source["key"];
+	        this.source = source["source"];
+	        this.name = source["name"];
 	    }
 	}
 	export class URLStatus {
 	    url: string;
+	    label: string;
 	    hasData: boolean;
 	    lineCount: number;
 	    error: boolean;
+	    durationMs: number;
+	    statusCode: number;
+	    bytes: number;
+	    charset: string;
+	    attempts: number;
+	    stale: boolean;
+	    staleMs: number;
+	    notModified: boolean;
+	    cacheHitRate: number;
+	    errorKind: string;
+	    message: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new URLStatus(source);
@@ -114,14 +360,27 @@ export namespace models {
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.url = source["url"];
+	        this.label = source["label"];
 	        this.hasData = source["hasData"];
 	        this.lineCount = source["lineCount"];
 	        this.error = source["error"];
+	        this.durationMs = source["durationMs"];
+	        this.statusCode = source["statusCode"];
+	        this.bytes = source["bytes"];
+	        this.charset = source["charset"];
+	        this.attempts = source["attempts"];
+	        this.stale = source["stale"];
+	        this.staleMs = source["staleMs"];
+	        this.notModified = source["notModified"];
+	        this.cacheHitRate = source["cacheHitRate"];
+	        this.errorKind = source["errorKind"];
+	        this.message = source["message"];
 	    }
 	}
 	export class PreviewResult {
 	    rawData: Data[];
 	    data: Data[];
+	    decisions: LineDecision[];
 	    statuses: URLStatus[];
 	
 	    static createFrom(source: any = {}) {
@@ -132,6 +391,7 @@ export namespace models {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.rawData = this.convertValues(source["rawData"], Data);
 	        this.data = this.convertValues(source["data"], Data);
+	        this.decisions = this.convertValues(source["decisions"], LineDecision);
 	        this.statuses = this.convertValues(source["statuses"], URLStatus);
 	    }
 	
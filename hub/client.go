@@ -0,0 +1,202 @@
+package hub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	sendBuffer   = 64
+	maxMessage   = 4096
+	writeTimeout = 10 * time.Second
+	pongTimeout  = 60 * time.Second
+	pingInterval = pongTimeout * 9 / 10
+)
+
+// Client commands.
+const (
+	actionSubscribe   = "subscribe"
+	actionUnsubscribe = "unsubscribe"
+	actionPing        = "ping"
+	actionStop        = "stop"
+)
+
+// command is a message sent by a client.
+type command struct {
+	Action string   `json:"action"`
+	Events []string `json:"events"`
+}
+
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+
+	mu     sync.Mutex
+	events map[string]bool
+	closed bool
+}
+
+func (c *client) subscribed(event string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.events[event]
+}
+
+// enqueue queues msg for sending and closes the client when its queue is
+// full.
+func (c *client) enqueue(msg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	select {
+	case c.send <- msg:
+	default:
+		c.closed = true
+		close(c.send)
+	}
+}
+
+func (c *client) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+	}
+}
+
+func (c *client) reply(event string, payload any) {
+	msg, err := json.Marshal(Message{Event: event, Payload: payload})
+	if err == nil {
+		c.enqueue(msg)
+	}
+}
+
+// Handler upgrades requests to WebSocket connections. Events to subscribe
+// to right away can be listed in the events query parameter, separated by
+// commas. As clients can stop the scraper, browsers may only connect from
+// pages served by this host or from the listed origins.
+func (h *Hub) Handler(origins []string) http.HandlerFunc {
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if origin == "" || slices.Contains(origins, origin) {
+				return true
+			}
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, r.Host)
+		},
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			slog.Debug("WebSocket upgrade failed", "remote", r.RemoteAddr, "err", err)
+			return
+		}
+		slog.Debug("WebSocket client connected", "remote", r.RemoteAddr)
+		c := &client{conn: conn, send: make(chan []byte, sendBuffer), events: make(map[string]bool)}
+		h.add(c)
+		go c.writeLoop()
+		if q := r.URL.Query().Get("events"); q != "" {
+			h.handle(c, command{Action: actionSubscribe, Events: strings.Split(q, ",")})
+		}
+		h.readLoop(c)
+		slog.Debug("WebSocket client disconnected", "remote", r.RemoteAddr)
+	}
+}
+
+func (h *Hub) readLoop(c *client) {
+	defer func() {
+		h.remove(c)
+		c.close()
+	}()
+	c.conn.SetReadLimit(maxMessage)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+	for {
+		var cmd command
+		if err := c.conn.ReadJSON(&cmd); err != nil {
+			var syntaxErr *json.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				return
+			}
+			c.reply("error", "invalid JSON command")
+			continue
+		}
+		h.handle(c, cmd)
+	}
+}
+
+func (h *Hub) handle(c *client, cmd command) {
+	switch cmd.Action {
+	case actionSubscribe, actionUnsubscribe:
+		for _, e := range cmd.Events {
+			if !knownEvent(strings.TrimSpace(e)) {
+				c.reply("error", fmt.Sprintf("unknown event %q", e))
+				return
+			}
+		}
+		c.mu.Lock()
+		for _, e := range cmd.Events {
+			c.events[strings.TrimSpace(e)] = cmd.Action == actionSubscribe
+		}
+		c.mu.Unlock()
+		c.reply("ack", cmd)
+		// New data subscribers get the latest data right away
+		if cmd.Action == actionSubscribe && slices.Contains(cmd.Events, EventData) {
+			if snap, ok := h.store.Latest(); ok {
+				c.reply(EventData, snap.Payload())
+			}
+		}
+	case actionPing:
+		c.reply("pong", nil)
+	case actionStop:
+		if h.commander == nil {
+			c.reply("error", "commands are not supported")
+			return
+		}
+		c.reply("ack", cmd)
+		h.commander.RequestScraperStop()
+	default:
+		c.reply("error", fmt.Sprintf("unknown action %q", cmd.Action))
+	}
+}
+
+func (c *client) writeLoop() {
+	ping := time.NewTicker(pingInterval)
+	defer func() {
+		ping.Stop()
+		_ = c.conn.Close()
+	}()
+	for {
+		select {
+		case msg, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, nil)
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Package hub fans out the events the app emits to its frontend to
+// WebSocket clients, such as overlays and playout automation. Clients
+// choose the events they receive and can send commands back.
+package hub
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+
+	"github.com/batijo/poll-scraper/snapshot"
+)
+
+// Events emitted by the app, with the same names and payloads as the
+// frontend events.
+const (
+	EventData      = "polled:data"
+	EventURLStatus = "polled:url-status"
+	EventState     = "polled:state"
+	EventLog       = "polled:log"
+	EventError     = "polled:error"
+)
+
+// Events is the list of events clients can subscribe to.
+var Events = []string{EventData, EventURLStatus, EventState, EventLog, EventError}
+
+// Commander carries out the commands clients send.
+type Commander interface {
+	RequestScraperStop()
+}
+
+// Message is sent to clients for every event and as a reply to commands.
+type Message struct {
+	Event   string `json:"event"`
+	Payload any    `json:"payload,omitempty"`
+}
+
+// Hub tracks the connected clients. It is safe for concurrent use.
+type Hub struct {
+	store     *snapshot.Store
+	commander Commander
+
+	mu      sync.RWMutex
+	clients map[*client]struct{}
+}
+
+func New(store *snapshot.Store, commander Commander) *Hub {
+	return &Hub{store: store, commander: commander, clients: make(map[*client]struct{})}
+}
+
+// Publish sends an event to every client subscribed to it. Clients that
+// cannot keep up are disconnected rather than slowing the caller down.
+// Publish does not log, as it also carries log events.
+func (h *Hub) Publish(event string, payload any) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var msg []byte
+	for c := range h.clients {
+		if !c.subscribed(event) {
+			continue
+		}
+		if msg == nil {
+			var err error
+			if msg, err = json.Marshal(Message{Event: event, Payload: payload}); err != nil {
+				return
+			}
+		}
+		c.enqueue(msg)
+	}
+}
+
+// Close disconnects every client.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.clients {
+		c.close()
+		delete(h.clients, c)
+	}
+}
+
+func (h *Hub) add(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[c] = struct{}{}
+}
+
+func (h *Hub) remove(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, c)
+}
+
+func knownEvent(event string) bool {
+	return slices.Contains(Events, event)
+}
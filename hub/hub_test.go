@@ -0,0 +1,164 @@
+package hub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/batijo/poll-scraper/models"
+	"github.com/batijo/poll-scraper/snapshot"
+)
+
+type stopRecorder chan struct{}
+
+func (s stopRecorder) RequestScraperStop() { s <- struct{}{} }
+
+func dial(t *testing.T, srv *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+query, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) map[string]any {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg map[string]any
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+	return msg
+}
+
+func TestSubscribe_ReceivesLatestDataAndEvents(t *testing.T) {
+	store := snapshot.NewStore()
+	_ = store.Publish(snapshot.Snapshot{Data: []models.Data{{Name: "A", Value: "1"}}})
+	h := New(store, nil)
+	srv := httptest.NewServer(h.Handler(nil))
+	defer srv.Close()
+	conn := dial(t, srv, "")
+
+	_ = conn.WriteJSON(command{Action: actionSubscribe, Events: []string{EventData, EventState}})
+	if msg := read(t, conn); msg["event"] != "ack" {
+		t.Fatalf("got %v, want ack", msg)
+	}
+	msg := read(t, conn)
+	payload, _ := msg["payload"].(map[string]any)
+	if msg["event"] != EventData || payload["data"] == nil {
+		t.Fatalf("got %v, want the latest data", msg)
+	}
+
+	h.Publish(EventLog, "not subscribed")
+	h.Publish(EventState, "running")
+	if msg := read(t, conn); msg["event"] != EventState || msg["payload"] != "running" {
+		t.Errorf("got %v, want the state event", msg)
+	}
+}
+
+func TestHandler_SubscribesFromQuery(t *testing.T) {
+	h := New(snapshot.NewStore(), nil)
+	srv := httptest.NewServer(h.Handler(nil))
+	defer srv.Close()
+	conn := dial(t, srv, "?events="+EventLog)
+
+	if msg := read(t, conn); msg["event"] != "ack" {
+		t.Fatalf("got %v, want ack", msg)
+	}
+	h.Publish(EventLog, "hello")
+	if msg := read(t, conn); msg["event"] != EventLog || msg["payload"] != "hello" {
+		t.Errorf("got %v, want the log event", msg)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	stops := make(stopRecorder, 1)
+	srv := httptest.NewServer(New(snapshot.NewStore(), stops).Handler(nil))
+	defer srv.Close()
+	conn := dial(t, srv, "")
+
+	tests := []struct {
+		cmd   command
+		event string
+	}{
+		{command{Action: actionPing}, "pong"},
+		{command{Action: actionSubscribe, Events: []string{"polled:unknown"}}, "error"},
+		{command{Action: "restart"}, "error"},
+		{command{Action: actionStop}, "ack"},
+	}
+	for _, tt := range tests {
+		_ = conn.WriteJSON(tt.cmd)
+		if msg := read(t, conn); msg["event"] != tt.event {
+			t.Errorf("%s: got %v, want %s", tt.cmd.Action, msg, tt.event)
+		}
+	}
+	select {
+	case <-stops:
+	case <-time.After(2 * time.Second):
+		t.Error("stop command did not stop the scraper")
+	}
+}
+
+func TestHandler_RejectsUnknownOrigin(t *testing.T) {
+	srv := httptest.NewServer(New(snapshot.NewStore(), nil).Handler([]string{"http://allowed"}))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"http://other"}})
+	if err == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unknown origin: err = %v, want 403", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"http://allowed"}})
+	if err != nil {
+		t.Fatalf("allowed origin: Dial() error = %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestHandler_ForeignOriginCannotStopByDefault(t *testing.T) {
+	stops := make(stopRecorder, 1)
+	srv := httptest.NewServer(New(snapshot.NewStore(), stops).Handler(nil))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"http://evil.example"}})
+	if err == nil {
+		_ = conn.WriteJSON(command{Action: actionStop})
+		_ = conn.Close()
+		t.Fatal("foreign origin connected with no domains configured")
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+	select {
+	case <-stops:
+		t.Error("foreign origin stopped the scraper")
+	default:
+	}
+
+	conn, _, err = websocket.DefaultDialer.Dial(url, http.Header{"Origin": {srv.URL}})
+	if err != nil {
+		t.Fatalf("same origin: Dial() error = %v", err)
+	}
+	_ = conn.Close()
+}
+
+func TestClose_DisconnectsClients(t *testing.T) {
+	h := New(snapshot.NewStore(), nil)
+	srv := httptest.NewServer(h.Handler(nil))
+	defer srv.Close()
+	conn := dial(t, srv, "?events="+EventLog)
+	read(t, conn)
+
+	h.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNoStatusReceived) {
+		t.Errorf("ReadMessage() error = %v, want close", err)
+	}
+}
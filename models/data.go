@@ -3,54 +3,169 @@ package models
 import (
 	"fmt"
 	"log/slog"
-	"strconv"
+	"math/big"
+	"strings"
+	"time"
 )
 
 type Data struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
+	// Number is the numeric value parsed from Value in canonical form
+	// ("-1234.5"), or empty when Value holds no number.
+	Number string `json:"number,omitempty"`
+	// Source is the URL the line was scraped from and Key identifies the
+	// line across cycles, see AssignKeys. Both are empty for custom and sum
+	// lines.
+	Source string `json:"source,omitempty"`
+	Key    string `json:"key,omitempty"`
 }
 
 type URLStatus struct {
-	URL       string `json:"url"`
-	HasData   bool   `json:"hasData"`
-	LineCount int    `json:"lineCount"`
-	Error     bool   `json:"error"`
+	URL          string  `json:"url"`
+	Label        string  `json:"label"`
+	HasData      bool    `json:"hasData"`
+	LineCount    int     `json:"lineCount"`
+	Error        bool    `json:"error"`
+	DurationMs   int64   `json:"durationMs"`
+	StatusCode   int     `json:"statusCode"`
+	Bytes        int     `json:"bytes"`
+	Charset      string  `json:"charset"`
+	Attempts     int     `json:"attempts"`
+	Stale        bool    `json:"stale"`
+	StaleMs      int64   `json:"staleMs"`
+	NotModified  bool    `json:"notModified"`
+	CacheHitRate float64 `json:"cacheHitRate"`
+	ErrorKind    string  `json:"errorKind"`
+	Message      string  `json:"message"`
 }
 
-type PreviewResult struct {
-	RawData  []Data      `json:"rawData"`
-	Data     []Data      `json:"data"`
-	Statuses []URLStatus `json:"statuses"`
+// DataPayload is the data of one scrape cycle as pushed to the UI in the
+// polled:data event and to stream clients.
+type DataPayload struct {
+	Data      []Data `json:"data"`
+	RawData   []Data `json:"rawData"`
+	Timestamp string `json:"timestamp"`
 }
 
-func FilterData(lines []int, unfilteredData []Data) []Data {
-	var data []Data
-	for _, l := range lines {
-		if l < len(unfilteredData) && l > -1 {
-			data = append(data, unfilteredData[l])
-		}
+// NewDataPayload builds the payload of a cycle finished at t.
+func NewDataPayload(data, rawData []Data, t time.Time) DataPayload {
+	if data == nil {
+		data = []Data{}
 	}
-	return data
+	if rawData == nil {
+		rawData = []Data{}
+	}
+	return DataPayload{Data: data, RawData: rawData, Timestamp: t.Format(time.RFC3339)}
+}
+
+type PreviewResult struct {
+	RawData []Data `json:"rawData"`
+	Data    []Data `json:"data"`
+	// Decisions holds, for every line of RawData, whether it was kept and
+	// which filter or rule decided.
+	Decisions []LineDecision `json:"decisions"`
+	Statuses  []URLStatus    `json:"statuses"`
+}
+
+// AutoDecimals makes SumData keep as many decimal places as the most precise
+// summed value.
+const AutoDecimals = -1
+
+// SumFormat controls how SumData renders the sum lines.
+type SumFormat struct {
+	// Decimals is the number of decimal places, or AutoDecimals.
+	Decimals           int
+	DecimalSeparator   string
+	ThousandsSeparator string
+	Symbol             string
+	SymbolPrefix       bool
 }
 
-func SumData(data []Data, sumSymbols string) []Data {
-	var sum int
+// SumData appends the exact decimal sum of all numeric values as a "sum"
+// line and, when a symbol is set, a "sum_symbol" line, both formatted with
+// f. Lines without a parsed number, such as custom lines, are parsed from
+// their value.
+func SumData(data []Data, f SumFormat) []Data {
+	sum := new(big.Rat)
+	decimals := 0
 	for _, d := range data {
-		v, err := strconv.Atoi(d.Value)
-		if err != nil {
-			slog.Debug(fmt.Sprintf("cannot convert value of [%s] to integer", d.Value), "err", err)
+		n := d.Number
+		if n == "" {
+			n, _ = ParseNumber(d.Value, "")
+		}
+		v, ok := new(big.Rat).SetString(n)
+		if n == "" || !ok {
+			slog.Debug(fmt.Sprintf("cannot convert value of [%s] to a number", d.Value))
 			continue
 		}
-		sum += v
+		if _, frac, found := strings.Cut(n, "."); found {
+			decimals = max(decimals, len(frac))
+		}
+		sum.Add(sum, v)
+	}
+	if f.Decimals != AutoDecimals {
+		decimals = f.Decimals
 	}
-	data = append(data, Data{Name: "sum", Value: strconv.Itoa(sum)})
-	if sumSymbols != "" {
-		data = append(data, Data{Name: "sum_symbol", Value: strconv.Itoa(sum) + sumSymbols})
+	total := roundRat(sum, decimals)
+	formatted := f.FormatNumber(total)
+	data = append(data, Data{Name: "sum", Value: formatted, Number: total})
+	if f.Symbol != "" {
+		withSymbol := formatted + f.Symbol
+		if f.SymbolPrefix {
+			withSymbol = f.Symbol + formatted
+		}
+		data = append(data, Data{Name: "sum_symbol", Value: withSymbol, Number: total})
 	}
 	return data
 }
 
+// RoundNumber rounds the canonical number n half away from zero to the
+// given number of decimal places. n is returned unchanged when it is not a
+// number.
+func RoundNumber(n string, decimals int) string {
+	r, ok := new(big.Rat).SetString(n)
+	if !ok {
+		return n
+	}
+	return roundRat(r, decimals)
+}
+
+func roundRat(r *big.Rat, decimals int) string {
+	s := r.FloatString(decimals)
+	if strings.Trim(s, "-0.") == "" {
+		s = strings.TrimPrefix(s, "-")
+	}
+	return s
+}
+
+// FormatNumber renders the canonical number n with the separators of f.
+func (f SumFormat) FormatNumber(n string) string {
+	sign := ""
+	if strings.HasPrefix(n, "-") {
+		sign, n = "-", n[1:]
+	}
+	intPart, fracPart, hasFrac := strings.Cut(n, ".")
+	if f.ThousandsSeparator != "" {
+		var b strings.Builder
+		for i, r := range intPart {
+			if i > 0 && (len(intPart)-i)%thousandsGroup == 0 {
+				b.WriteString(f.ThousandsSeparator)
+			}
+			b.WriteRune(r)
+		}
+		intPart = b.String()
+	}
+	if !hasFrac {
+		return sign + intPart
+	}
+	sep := f.DecimalSeparator
+	if sep == "" {
+		sep = "."
+	}
+	return sign + intPart + sep + fracPart
+}
+
 func AddLines(data, lines []Data) []Data {
 	if len(lines) == 0 {
 		return data
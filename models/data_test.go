@@ -1,21 +1,54 @@
 package models
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
-func TestFilterData(t *testing.T) {
-	data := []Data{
-		{Name: "A", Value: "1"},
-		{Name: "B", Value: "2"},
-		{Name: "C", Value: "3"},
+func TestAssignKeys_NumbersRepeatedNames(t *testing.T) {
+	data := []Data{{Name: "Total"}, {Name: "A"}, {Name: "Total"}}
+
+	AssignKeys(data, "http://a")
+
+	want := []string{"http://a#Total", "http://a#A", "http://a#Total#2"}
+	for i, d := range data {
+		if d.Key != want[i] || d.Source != "http://a" {
+			t.Errorf("line %d = {%q, %q}, want {%q, %q}", i, d.Source, d.Key, "http://a", want[i])
+		}
+	}
+}
+
+func TestFilterData_SurvivesShiftedLines(t *testing.T) {
+	first := []Data{{Name: "A"}, {Name: "B"}}
+	second := []Data{{Name: "New"}, {Name: "C"}, {Name: "D"}}
+	AssignKeys(first, "http://one")
+	AssignKeys(second, "http://two")
+	data := append(first, second...)
+	filters := []LineFilter{
+		{Key: "http://two#D"},
+		{Source: "http://one", Name: "B"},
+		{Name: "C"},
 	}
 
-	result := FilterData([]int{0, 2}, data)
+	result := FilterData(filters, data)
 
-	if len(result) != 2 {
-		t.Fatalf("got %d items, want 2", len(result))
+	var got []string
+	for _, d := range result {
+		got = append(got, d.Name)
 	}
-	if result[0].Name != "A" || result[1].Name != "C" {
-		t.Errorf("got [%s, %s], want [A, C]", result[0].Name, result[1].Name)
+	if strings.Join(got, ",") != "D,B,C" {
+		t.Errorf("got %v, want [D B C]", got)
+	}
+}
+
+func TestFiltersFromIndices(t *testing.T) {
+	data := []Data{{Name: "A"}, {Name: "B"}, {Name: "C"}}
+	AssignKeys(data, "http://a")
+
+	filters := FiltersFromIndices([]int{0, 2, 7}, data)
+
+	if len(filters) != 2 || filters[0].Key != "http://a#A" || filters[1].Key != "http://a#C" {
+		t.Errorf("got %+v, want keys for A and C", filters)
 	}
 }
 
@@ -25,7 +58,7 @@ func TestSumData(t *testing.T) {
 		{Name: "B", Value: "20"},
 	}
 
-	result := SumData(data, "")
+	result := SumData(data, SumFormat{Decimals: AutoDecimals})
 
 	if len(result) != 3 {
 		t.Fatalf("got %d items, want 3", len(result))
@@ -41,7 +74,7 @@ func TestSumData_WithSymbol(t *testing.T) {
 		{Name: "B", Value: "20"},
 	}
 
-	result := SumData(data, "$")
+	result := SumData(data, SumFormat{Decimals: AutoDecimals, Symbol: "$"})
 
 	if len(result) != 4 {
 		t.Fatalf("got %d items, want 4", len(result))
@@ -71,3 +104,186 @@ func TestAddLines(t *testing.T) {
 		t.Errorf("got {%s, %s}, want {custom2, bar}", result[1].Name, result[1].Value)
 	}
 }
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in, sep, want string
+		ok            bool
+	}{
+		{" 100 ", "", "100", true},
+		{"1 234", "", "1234", true},
+		{"1\u00a0234\u00a0567", "", "1234567", true},
+		{"1.234", "", "1234", true},
+		{"1,234,567", "", "1234567", true},
+		{"12,5 %", "", "12.5", true},
+		{"1.234,56 €", "", "1234.56", true},
+		{"1,234.56", "", "1234.56", true},
+		{"0,123", "", "0.123", true},
+		{"1234.567", "", "1234.567", true},
+		{"1234,567", "", "1234.567", true},
+		{"1234,5", "", "1234.5", true},
+		{"1 234.567", "", "1234.567", true},
+		{"1.234", ",", "1234", true},
+		{"1.234", ".", "1.234", true},
+		{"-3,5", "", "-3.5", true},
+		{"$ 12.50", "", "12.50", true},
+		{"-€5", "", "-5", true},
+		{"1'234", "", "1234", true},
+		{"42 votes", "", "42", true},
+		{"Votes: 42 (55%)", "", "", false},
+		{"Updated 12:30", "", "", false},
+		{"2024-05-01", "", "", false},
+		{"01.05.2024", "", "", false},
+		{"Round 2", "", "", false},
+		{"10 20", "", "", false},
+		{"1 2345", "", "", false},
+		{"007", "", "7", true},
+		{"n/a", "", "", false},
+		{"1,2,3", ",", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseNumber(tt.in, tt.sep)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ParseNumber(%q, %q) = %q, %v; want %q, %v", tt.in, tt.sep, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNormalizeData(t *testing.T) {
+	data := []Data{{Name: " Alice ", Value: " 1 234 "}, {Name: "Note", Value: "text"}}
+
+	NormalizeData(data, "")
+
+	if data[0].Name != "Alice" || data[0].Value != "1 234" || data[0].Number != "1234" {
+		t.Errorf("got %+v, want trimmed Alice with number 1234", data[0])
+	}
+	if data[1].Number != "" {
+		t.Errorf("got number %q for text value, want empty", data[1].Number)
+	}
+}
+
+func TestSumData_UsesParsedNumbers(t *testing.T) {
+	data := []Data{
+		{Name: "A", Value: "1 234", Number: "1234"},
+		{Name: "B", Value: "12,5 %", Number: "12.5"},
+		{Name: "C", Value: "text"},
+		{Name: "D", Value: "Updated 12:30"},
+		{Name: "custom", Value: "100"},
+	}
+
+	result := SumData(data, SumFormat{Decimals: AutoDecimals})
+
+	if got := result[len(result)-1].Value; got != "1346.5" {
+		t.Errorf("sum = %q, want %q", got, "1346.5")
+	}
+}
+
+func TestSumData_Formatting(t *testing.T) {
+	data := []Data{
+		{Name: "A", Value: "1234.5", Number: "1234.5"},
+		{Name: "B", Value: "0.25", Number: "0.25"},
+	}
+	tests := []struct {
+		name       string
+		format     SumFormat
+		sum        string
+		withSymbol string
+	}{
+		{"auto decimals", SumFormat{Decimals: AutoDecimals, Symbol: "€"}, "1234.75", "1234.75€"},
+		{"rounded", SumFormat{Decimals: 1, Symbol: "€"}, "1234.8", "1234.8€"},
+		{"padded", SumFormat{Decimals: 3, Symbol: "€"}, "1234.750", "1234.750€"},
+		{
+			"european",
+			SumFormat{Decimals: 2, DecimalSeparator: ",", ThousandsSeparator: ".", Symbol: " €"},
+			"1.234,75", "1.234,75 €",
+		},
+		{"prefix", SumFormat{Decimals: 0, ThousandsSeparator: ",", Symbol: "$", SymbolPrefix: true}, "1,235", "$1,235"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := SumData(data, tt.format)
+
+			if got := result[2].Value; got != tt.sum {
+				t.Errorf("sum = %q, want %q", got, tt.sum)
+			}
+			if got := result[3].Value; got != tt.withSymbol {
+				t.Errorf("sum_symbol = %q, want %q", got, tt.withSymbol)
+			}
+			if result[2].Number != result[3].Number {
+				t.Errorf("sum numbers differ: %q and %q", result[2].Number, result[3].Number)
+			}
+		})
+	}
+}
+
+func TestFormatNumber_GroupsNegative(t *testing.T) {
+	f := SumFormat{ThousandsSeparator: " "}
+
+	if got := f.FormatNumber("-1234567.5"); got != "-1 234 567.5" {
+		t.Errorf("FormatNumber() = %q, want %q", got, "-1 234 567.5")
+	}
+}
+
+func TestRuleSet_FirstMatchDecides(t *testing.T) {
+	rules, err := CompileRules([]FilterRule{
+		{Action: RuleExclude, Name: "^Total"},
+		{Action: RuleInclude, Value: "> 0"},
+		{Action: RuleExclude},
+	})
+	if err != nil {
+		t.Fatalf("CompileRules() error = %v", err)
+	}
+	tests := []struct {
+		line Data
+		kept bool
+		rule int
+	}{
+		{Data{Name: "Total", Number: "10"}, false, 0},
+		{Data{Name: "A", Number: "2.5"}, true, 1},
+		{Data{Name: "B", Number: "0"}, false, 2},
+		{Data{Name: "C"}, false, 2},
+	}
+
+	for _, tt := range tests {
+		got := rules.Decide(tt.line)
+		if got.Kept != tt.kept || got.Rule != tt.rule {
+			t.Errorf("Decide(%s) = %+v, want kept %v by rule %d", tt.line.Name, got, tt.kept, tt.rule)
+		}
+	}
+}
+
+func TestRuleSet_KeepsUnmatchedLines(t *testing.T) {
+	rules, _ := CompileRules([]FilterRule{{Action: RuleExclude, Source: "http://a", Value: "<= -1"}})
+
+	if got := rules.Decide(Data{Source: "http://b", Number: "-5"}); !got.Kept || got.Rule != -1 {
+		t.Errorf("Decide() = %+v, want kept without a rule", got)
+	}
+}
+
+func TestRuleSet_IncludeRulesDropUnmatchedLines(t *testing.T) {
+	rules, _ := CompileRules([]FilterRule{
+		{Action: RuleExclude, Name: "^Total"},
+		{Action: RuleInclude, Value: "> 0"},
+	})
+
+	if got := rules.Decide(Data{Name: "A", Number: "0"}); got.Kept || got.Rule != -1 {
+		t.Errorf("Decide() = %+v, want dropped without a rule", got)
+	}
+	if got := rules.Decide(Data{Name: "B", Number: "3"}); !got.Kept || got.Rule != 1 {
+		t.Errorf("Decide() = %+v, want kept by rule 1", got)
+	}
+}
+
+func TestCompileRules_RejectsInvalidRules(t *testing.T) {
+	for _, r := range []FilterRule{
+		{Action: "keep"},
+		{Action: RuleInclude, Name: "("},
+		{Action: RuleInclude, Value: "about 5"},
+		{Action: RuleInclude, Value: ">"},
+	} {
+		if _, err := CompileRules([]FilterRule{r}); err == nil {
+			t.Errorf("CompileRules(%+v) expected error", r)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import "strconv"
+
+// LineFilter selects scraped lines. A filter with a Key selects the line with
+// that key; otherwise it selects every line named Name from Source, or from
+// any source when Source is empty.
+type LineFilter struct {
+	Key    string `json:"key,omitempty"`
+	Source string `json:"source,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+func (f LineFilter) matches(d Data) bool {
+	if f.Key != "" {
+		return d.Key == f.Key
+	}
+	return d.Name == f.Name && (f.Source == "" || d.Source == f.Source)
+}
+
+// AssignKeys marks data as scraped from source and gives every line the key
+// "<source>#<name>". Repeated names within the source get an occurrence
+// suffix, so the second "Total" line is "<source>#Total#2".
+func AssignKeys(data []Data, source string) {
+	seen := make(map[string]int, len(data))
+	for i := range data {
+		data[i].Source = source
+		seen[data[i].Name]++
+		data[i].Key = source + "#" + data[i].Name
+		if n := seen[data[i].Name]; n > 1 {
+			data[i].Key += "#" + strconv.Itoa(n)
+		}
+	}
+}
+
+// FilterData returns the lines selected by filters, in filter order. The
+// filters are resolved against the keys and names of data, so they keep
+// selecting the same lines when a source gains or loses rows.
+func FilterData(filters []LineFilter, unfilteredData []Data) []Data {
+	var data []Data
+	for _, i := range SelectLines(filters, unfilteredData) {
+		data = append(data, unfilteredData[i])
+	}
+	return data
+}
+
+// SelectLines returns the positions in data of the lines FilterData selects.
+func SelectLines(filters []LineFilter, data []Data) []int {
+	var lines []int
+	picked := make([]bool, len(data))
+	for _, f := range filters {
+		for i, d := range data {
+			if !picked[i] && f.matches(d) {
+				picked[i] = true
+				lines = append(lines, i)
+			}
+		}
+	}
+	return lines
+}
+
+// FiltersFromIndices converts zero-based positions into key filters using the
+// lines of a complete scrape. Positions out of range are dropped.
+func FiltersFromIndices(lines []int, data []Data) []LineFilter {
+	filters := make([]LineFilter, 0, len(lines))
+	for _, l := range lines {
+		if l < 0 || l >= len(data) || data[l].Key == "" {
+			continue
+		}
+		filters = append(filters, LineFilter{Key: data[l].Key})
+	}
+	return filters
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// numberValue matches a value that is a single number, optionally with a
+// currency before it and a currency, unit or percent sign after it, e.g.
+// "1 234,5 EUR" or "$ 12.50". Spaces (also non-breaking and thin ones) only
+// group digits in threes, so "10 20" or "2024-05-01" are not numbers.
+var numberValue = regexp.MustCompile(`^([-−]?)\s*(?:\p{Sc}|[A-Z]{3})?\s*([-−]?)` +
+	`(\d{1,3}(?:[ \x{00A0}\x{2009}\x{202F}]\d{3})+(?:[.,]\d+)?|\d+(?:[.,']\d+)*[.,]?)` +
+	`\s*(?:%|‰|\p{Sc}|\p{L}+\.?)?$`)
+
+const thousandsGroup = 3
+
+// ParseNumber parses s when it is a number, optionally with a currency or
+// unit, and returns it in canonical form: an optional minus sign, digits and
+// an optional "." with the fractional digits. Units and thousands separators
+// are dropped; text with anything else in it is not a number. decimalSep is
+// "." or ","; when empty, it is inferred: with both separators present the
+// last one is decimal, and a single separator with one to three digits
+// before it and exactly three after it is a thousands separator.
+func ParseNumber(s, decimalSep string) (string, bool) {
+	m := numberValue.FindStringSubmatch(strings.TrimFunc(s, unicode.IsSpace))
+	if m == nil {
+		return "", false
+	}
+	negative := m[1] != "" || m[2] != ""
+	token := strings.TrimRight(m[3], ".,")
+	isGrouping := func(r rune) bool { return unicode.IsSpace(r) || r == '\'' }
+
+	if decimalSep == "" {
+		decimalSep = inferDecimalSeparator(strings.Map(func(r rune) rune {
+			if isGrouping(r) {
+				return -1
+			}
+			return r
+		}, token))
+	}
+	thousandsSep := ","
+	if decimalSep == "," {
+		thousandsSep = "."
+	}
+	token = strings.Map(func(r rune) rune {
+		if isGrouping(r) {
+			return rune(thousandsSep[0])
+		}
+		return r
+	}, token)
+	intPart, fracPart, _ := strings.Cut(token, decimalSep)
+	if strings.ContainsAny(fracPart, ".,") {
+		return "", false
+	}
+	// Every group after the first has three digits, so "01.05.2024" is not
+	// a number
+	groups := strings.Split(intPart, thousandsSep)
+	for i, g := range groups[1:] {
+		if len(g) != thousandsGroup || (i == 0 && len(groups[0]) > thousandsGroup) {
+			return "", false
+		}
+	}
+	intPart = strings.Join(groups, "")
+
+	intPart = strings.TrimLeft(intPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+	n := intPart
+	if fracPart != "" {
+		n += "." + fracPart
+	}
+	if negative && strings.Trim(n, "0.") != "" {
+		n = "-" + n
+	}
+	return n, true
+}
+
+func inferDecimalSeparator(token string) string {
+	lastDot, lastComma := strings.LastIndex(token, "."), strings.LastIndex(token, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastComma > lastDot {
+			return ","
+		}
+		return "."
+	case lastDot < 0 && lastComma < 0:
+		return "."
+	}
+	sep, last := ".", lastDot
+	if lastComma >= 0 {
+		sep, last = ",", lastComma
+	}
+	if strings.Count(token, sep) > 1 {
+		// repeated separators can only group thousands
+		return otherSeparator(sep)
+	}
+	// A leading group of more than three digits cannot be followed by a
+	// thousands separator
+	intPart := token[:last]
+	if len(token)-last-1 == thousandsGroup && len(intPart) <= thousandsGroup && !strings.HasPrefix(intPart, "0") {
+		return otherSeparator(sep)
+	}
+	return sep
+}
+
+func otherSeparator(sep string) string {
+	if sep == "," {
+		return "."
+	}
+	return ","
+}
+
+// NormalizeData trims names and values and fills in the parsed number of
+// every value that contains one.
+func NormalizeData(data []Data, decimalSep string) {
+	for i := range data {
+		data[i].Name = strings.TrimFunc(data[i].Name, unicode.IsSpace)
+		data[i].Value = strings.TrimFunc(data[i].Value, unicode.IsSpace)
+		data[i].Number, _ = ParseNumber(data[i].Value, decimalSep)
+	}
+}
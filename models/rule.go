@@ -0,0 +1,172 @@
+package models
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// Filter rule actions.
+const (
+	RuleInclude = "include"
+	RuleExclude = "exclude"
+)
+
+// valueOperators are the comparison operators of a value condition, longest
+// first so that ">=" is not read as ">".
+var valueOperators = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// FilterRule keeps or drops the lines it matches. Name is a regular
+// expression matched against the line name, Source the URL of the source
+// and Value a condition on the parsed number such as "> 0" or "<= 12.5". A
+// line matches when it satisfies every criterion that is set, so a rule
+// without criteria matches every line.
+type FilterRule struct {
+	Action string `json:"action"`
+	Source string `json:"source,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Value  string `json:"value,omitempty"`
+}
+
+// String describes the rule, e.g. `exclude name ~ "^Total" value > 0`.
+func (r FilterRule) String() string {
+	parts := []string{r.Action}
+	if r.Source != "" {
+		parts = append(parts, "source "+r.Source)
+	}
+	if r.Name != "" {
+		parts = append(parts, fmt.Sprintf("name ~ %q", r.Name))
+	}
+	if r.Value != "" {
+		parts = append(parts, "value "+strings.TrimSpace(r.Value))
+	}
+	return strings.Join(parts, " ")
+}
+
+type compiledRule struct {
+	FilterRule
+	name  *regexp.Regexp
+	op    string
+	value *big.Rat
+}
+
+// RuleSet is a compiled list of filter rules.
+type RuleSet struct {
+	rules []compiledRule
+	// allowList is set when any rule includes lines, which makes the rules
+	// drop the lines none of them matches.
+	allowList bool
+}
+
+// LineDecision records whether a scraped line made it into the output. Rule
+// is the index of the filter rule that decided, or -1 when no rule matched.
+type LineDecision struct {
+	Kept   bool   `json:"kept"`
+	Rule   int    `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+// CompileRules validates rules and compiles their patterns and conditions.
+func CompileRules(rules []FilterRule) (*RuleSet, error) {
+	rs := &RuleSet{rules: make([]compiledRule, len(rules))}
+	for i, r := range rules {
+		c := compiledRule{FilterRule: r}
+		if r.Action != RuleInclude && r.Action != RuleExclude {
+			return nil, fmt.Errorf("rule %d: action must be %q or %q", i+1, RuleInclude, RuleExclude)
+		}
+		if r.Name != "" {
+			re, err := regexp.Compile(r.Name)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: invalid name pattern %q: %w", i+1, r.Name, err)
+			}
+			c.name = re
+		}
+		if r.Value != "" {
+			op, value, err := parseCondition(r.Value)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d: %w", i+1, err)
+			}
+			c.op, c.value = op, value
+		}
+		rs.rules[i] = c
+		if r.Action == RuleInclude {
+			rs.allowList = true
+		}
+	}
+	return rs, nil
+}
+
+func parseCondition(cond string) (string, *big.Rat, error) {
+	cond = strings.TrimSpace(cond)
+	for _, op := range valueOperators {
+		operand, ok := strings.CutPrefix(cond, op)
+		if !ok {
+			continue
+		}
+		n, ok := ParseNumber(operand, ".")
+		if !ok || strings.TrimSpace(operand) == "" {
+			break
+		}
+		v, _ := new(big.Rat).SetString(n)
+		return op, v, nil
+	}
+	return "", nil, fmt.Errorf("invalid value condition %q, want an operator and a number such as \"> 0\"", cond)
+}
+
+func (c *compiledRule) matches(d Data) bool {
+	if c.Source != "" && d.Source != c.Source {
+		return false
+	}
+	if c.name != nil && !c.name.MatchString(d.Name) {
+		return false
+	}
+	if c.value == nil {
+		return true
+	}
+	n, ok := new(big.Rat).SetString(d.Number)
+	if d.Number == "" || !ok {
+		return false
+	}
+	cmp := n.Cmp(c.value)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+// Decide evaluates the rules in order against d. The first matching rule
+// decides. A line no rule matches is dropped when any rule includes lines,
+// so include rules alone keep only what they match, and kept otherwise. A
+// nil RuleSet keeps every line.
+func (rs *RuleSet) Decide(d Data) LineDecision {
+	if rs == nil {
+		return LineDecision{Kept: true, Rule: -1, Reason: "kept, no rule matched"}
+	}
+	for i := range rs.rules {
+		r := &rs.rules[i]
+		if !r.matches(d) {
+			continue
+		}
+		kept := r.Action == RuleInclude
+		verb := "dropped"
+		if kept {
+			verb = "kept"
+		}
+		return LineDecision{Kept: kept, Rule: i, Reason: fmt.Sprintf("%s by rule %d (%s)", verb, i+1, r.FilterRule)}
+	}
+	if rs.allowList {
+		return LineDecision{Kept: false, Rule: -1, Reason: "dropped, no include rule matched"}
+	}
+	return LineDecision{Kept: true, Rule: -1, Reason: "kept, no rule matched"}
+}
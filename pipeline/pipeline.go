@@ -0,0 +1,99 @@
+// Package pipeline turns the raw lines of a scrape into the output lines.
+// The writer, the preview and the HTTP handler all run the same pipeline, an
+// ordered list of named stages taken from the config.
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+// Result is the state of one pass through the pipeline.
+type Result struct {
+	Data []models.Data
+	// Decisions holds, for every raw line, whether the filter stage kept
+	// it and why. It is nil when the pipeline does not filter. The config
+	// makes filter the first stage, so it always sees the raw lines.
+	Decisions []models.LineDecision
+}
+
+// Stage transforms the lines of a pass in place.
+type Stage func(res *Result)
+
+// Factory creates a stage configured from cfg.
+type Factory func(cfg *config.Config) (Stage, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		config.StageFilter:  newFilterStage,
+		config.StageAdd:     newAddStage,
+		config.StageCompute: newComputeStage,
+		config.StageSum:     newSumStage,
+		config.StageFormat:  newFormatStage,
+	}
+)
+
+// Register adds a stage under the given name, replacing any stage
+// previously registered under the same name.
+func Register(name string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = f
+	config.RegisterStage(name)
+}
+
+// Stages returns the names of all registered stages in sorted order.
+func Stages() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pipeline is an ordered list of stages.
+type Pipeline struct {
+	stages []Stage
+}
+
+// New builds the pipeline configured in cfg.Pipeline, or the default one
+// when it is empty.
+func New(cfg *config.Config) (*Pipeline, error) {
+	names := cfg.Pipeline
+	if len(names) == 0 {
+		names = config.DefaultPipeline()
+	}
+	p := &Pipeline{stages: make([]Stage, 0, len(names))}
+	for _, name := range names {
+		registryMu.RLock()
+		f, ok := registry[name]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown pipeline stage %q", name)
+		}
+		stage, err := f(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline stage %q: %w", name, err)
+		}
+		p.stages = append(p.stages, stage)
+	}
+	return p, nil
+}
+
+// Run passes a copy of rawData through every stage in order.
+func (p *Pipeline) Run(rawData []models.Data) Result {
+	res := Result{Data: make([]models.Data, len(rawData))}
+	copy(res.Data, rawData)
+	for _, stage := range p.stages {
+		stage(&res)
+	}
+	return res
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+func rawData() []models.Data {
+	data := []models.Data{
+		{Name: "A", Value: "1 000", Number: "1000"},
+		{Name: "B", Value: "0", Number: "0"},
+	}
+	models.AssignKeys(data, "http://a")
+	return data
+}
+
+func TestRun_DefaultStages(t *testing.T) {
+	cfg := &config.Config{
+		FilterRules: []models.FilterRule{{Action: models.RuleExclude, Value: "== 0"}},
+		AddLines: []config.AddLine{
+			{Name: "custom", Value: "5"},
+			{Name: "hidden", Value: "7", Filtered: true},
+		},
+		AddSum: true,
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	res := p.Run(rawData())
+
+	want := []string{"A", "custom", "sum"}
+	if len(res.Data) != len(want) {
+		t.Fatalf("got %+v, want lines %v", res.Data, want)
+	}
+	for i, name := range want {
+		if res.Data[i].Name != name {
+			t.Errorf("line %d = %q, want %q", i, res.Data[i].Name, name)
+		}
+	}
+	if res.Data[1].Number != "5" {
+		t.Errorf("custom line number = %q, want computed %q", res.Data[1].Number, "5")
+	}
+	if res.Data[2].Value != "1005" {
+		t.Errorf("sum = %q, want %q", res.Data[2].Value, "1005")
+	}
+	if len(res.Decisions) != 2 || res.Decisions[1].Kept {
+		t.Errorf("decisions = %+v, want B dropped", res.Decisions)
+	}
+}
+
+func TestRun_FormatStage(t *testing.T) {
+	decimals := 2
+	cfg := &config.Config{
+		Pipeline:              []string{config.StageFormat},
+		SumDecimals:           &decimals,
+		SumDecimalSeparator:   ",",
+		SumThousandsSeparator: ".",
+	}
+	p, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	raw := rawData()
+
+	res := p.Run(raw)
+
+	if res.Data[0].Value != "1.000,00" {
+		t.Errorf("formatted value = %q, want %q", res.Data[0].Value, "1.000,00")
+	}
+	if raw[0].Value != "1 000" {
+		t.Errorf("raw data was modified: %q", raw[0].Value)
+	}
+}
+
+func TestRegister_CustomStage(t *testing.T) {
+	Register("extra", func(*config.Config) (Stage, error) {
+		return func(res *Result) {
+			res.Data = append(res.Data, models.Data{Name: "extra"})
+		}, nil
+	})
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "extra")
+		registryMu.Unlock()
+	}()
+
+	p, err := New(&config.Config{Pipeline: []string{"extra"}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if res := p.Run(nil); len(res.Data) != 1 || res.Data[0].Name != "extra" {
+		t.Errorf("got %+v, want the custom stage line", res.Data)
+	}
+}
+
+func TestNew_UnknownStage(t *testing.T) {
+	if _, err := New(&config.Config{Pipeline: []string{"nope"}}); err == nil {
+		t.Error("expected error for unknown stage")
+	}
+}
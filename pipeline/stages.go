@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"log/slog"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+// newFilterStage selects lines with the configured filters and filter rules.
+func newFilterStage(cfg *config.Config) (Stage, error) {
+	rules, err := models.CompileRules(cfg.FilterRules)
+	if err != nil {
+		return nil, err
+	}
+	return func(res *Result) {
+		before := len(res.Data)
+		res.Data, res.Decisions = cfg.ExplainFilters(res.Data, rules)
+		if cfg.HasFilters() {
+			slog.Debug("filtered lines", "before", before, "after", len(res.Data))
+		}
+	}, nil
+}
+
+// newAddStage appends the custom lines that are not filtered out.
+func newAddStage(cfg *config.Config) (Stage, error) {
+	var lines []models.Data
+	for _, l := range cfg.AddLines {
+		if !l.Filtered {
+			lines = append(lines, models.Data{Name: l.Name, Value: l.Value})
+		}
+	}
+	return func(res *Result) {
+		if len(lines) == 0 {
+			return
+		}
+		res.Data = models.AddLines(res.Data, lines)
+		slog.Debug("added custom lines", "count", len(lines))
+	}, nil
+}
+
+// newComputeStage parses the number of every line that has none yet, such
+// as custom lines, so later stages and the output see it.
+func newComputeStage(*config.Config) (Stage, error) {
+	return func(res *Result) {
+		for i := range res.Data {
+			if res.Data[i].Number == "" {
+				res.Data[i].Number, _ = models.ParseNumber(res.Data[i].Value, "")
+			}
+		}
+	}, nil
+}
+
+// newSumStage appends the sum lines when add_sum is enabled.
+func newSumStage(cfg *config.Config) (Stage, error) {
+	return func(res *Result) {
+		if cfg.AddSum {
+			res.Data = models.SumData(res.Data, cfg.SumFormat())
+		}
+	}, nil
+}
+
+// newFormatStage rewrites the value of every scraped line that holds a
+// number with the sum number format, without its symbol, so scraped values
+// and the sum look alike. Custom and sum lines are left as they are.
+func newFormatStage(cfg *config.Config) (Stage, error) {
+	f := cfg.SumFormat()
+	return func(res *Result) {
+		for i, d := range res.Data {
+			if d.Source == "" || d.Number == "" {
+				continue
+			}
+			n := d.Number
+			if f.Decimals != models.AutoDecimals {
+				n = models.RoundNumber(n, f.Decimals)
+			}
+			res.Data[i].Value = f.FormatNumber(n)
+		}
+	}, nil
+}
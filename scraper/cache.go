@@ -0,0 +1,115 @@
+package scraper
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+// Cache keeps the validators and extracted data of each source, by its ID,
+// so that unchanged pages can be fetched with conditional requests and
+// answered from memory on 304 Not Modified.
+type Cache struct {
+	mu       sync.Mutex
+	entries  map[string]*cacheEntry
+	hits     int
+	requests int
+}
+
+type cacheEntry struct {
+	etag         string
+	lastModified string
+	data         []models.Data
+	valid        bool
+	hits         int
+	requests     int
+}
+
+func NewCache() *Cache {
+	return &Cache{entries: make(map[string]*cacheEntry)}
+}
+
+// setValidators adds the conditional request headers for id, if any
+// validators are known.
+func (c *Cache) setValidators(id string, h *http.Header) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok || !e.valid {
+		return
+	}
+	if e.etag != "" {
+		h.Set("If-None-Match", e.etag)
+	}
+	if e.lastModified != "" {
+		h.Set("If-Modified-Since", e.lastModified)
+	}
+}
+
+// store records a successfully extracted response for id. Responses
+// without validators are counted but not cached.
+func (c *Cache) store(id string, h *http.Header, data []models.Data) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	etag, lastModified := h.Get("ETag"), h.Get("Last-Modified")
+	e, ok := c.entries[id]
+	if !ok {
+		e = &cacheEntry{}
+		c.entries[id] = e
+	}
+	e.requests++
+	c.requests++
+	e.etag, e.lastModified, e.data = etag, lastModified, data
+	e.valid = etag != "" || lastModified != ""
+}
+
+// hit returns the cached data for id after a 304 response.
+func (c *Cache) hit(id string) ([]models.Data, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok || !e.valid {
+		return nil, false
+	}
+	e.hits++
+	e.requests++
+	c.hits++
+	c.requests++
+	return e.data, true
+}
+
+// HitRate returns the share of responses for the source with the given ID
+// answered from the cache.
+func (c *Cache) HitRate(id string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[id]
+	if !ok || e.requests == 0 {
+		return 0
+	}
+	return float64(e.hits) / float64(e.requests)
+}
+
+// TotalHitRate returns the share of all responses answered from the cache.
+func (c *Cache) TotalHitRate() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.requests == 0 {
+		return 0
+	}
+	return float64(c.hits) / float64(c.requests)
+}
+
+// Prune drops the entries of every source not in sources.
+func (c *Cache) Prune(sources []config.Source) {
+	keep := sourceIDs(sources)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id := range c.entries {
+		if !keep[id] {
+			delete(c.entries, id)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package scraper
+
+import (
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/net/html/charset"
+
+	"github.com/batijo/poll-scraper/models"
+)
+
+const (
+	fallbackCharset      = "windows-1252"
+	minCharsetConfidence = 50
+)
+
+// decodeBody converts body to UTF-8 and returns it with the name of the
+// charset it was decoded from. colly already decodes bodies whose
+// Content-Type names a charset; for the rest the charset is taken from a
+// BOM or meta tag, then guessed statistically.
+func decodeBody(body []byte, contentType string) ([]byte, string) {
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		return body, strings.ToLower(params["charset"])
+	}
+	if utf8.Valid(body) {
+		return body, "utf-8"
+	}
+	_, name, _ := charset.DetermineEncoding(body, contentType)
+	if name == fallbackCharset {
+		if guess, err := chardet.NewTextDetector().DetectBest(body); err == nil && guess.Confidence >= minCharsetConfidence {
+			name = strings.ToLower(guess.Charset)
+		}
+	}
+	enc, name := charset.Lookup(name)
+	if enc == nil {
+		return body, ""
+	}
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return body, ""
+	}
+	return decoded, name
+}
+
+// sanitizeUTF8 replaces invalid UTF-8 in names and values so that later
+// stages never see broken text.
+func sanitizeUTF8(data []models.Data) {
+	for i := range data {
+		data[i].Name = strings.ToValidUTF8(data[i].Name, "\uFFFD")
+		data[i].Value = strings.ToValidUTF8(data[i].Value, "\uFFFD")
+	}
+}
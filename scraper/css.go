@@ -0,0 +1,150 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+const (
+	defaultTableRow  = "tbody tr"
+	defaultTableCell = ".pdg"
+	defaultEqualsRow = "p"
+)
+
+// htmlElements parses the response body and returns every element matching
+// selector, in the same shape colly passes to OnHTML callbacks.
+func htmlElements(resp *colly.Response, selector string) ([]*colly.HTMLElement, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+	var elements []*colly.HTMLElement
+	doc.Find(selector).Each(func(i int, s *goquery.Selection) {
+		for _, n := range s.Nodes {
+			elements = append(elements, colly.NewHTMLElementFromSelectionNode(resp, s, n, i))
+		}
+	})
+	return elements, nil
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// tableExtractor reads name/value pairs from the first two cells of each
+// table row.
+type tableExtractor struct {
+	row, cell string
+}
+
+func newTableExtractor(src config.Source) (Extractor, error) {
+	return tableExtractor{
+		row:  orDefault(src.Selectors.Row, defaultTableRow),
+		cell: orDefault(src.Selectors.Cell, defaultTableCell),
+	}, nil
+}
+
+func (t tableExtractor) Extract(resp *colly.Response) ([]models.Data, error) {
+	elements, err := htmlElements(resp, t.row)
+	if err != nil {
+		return nil, err
+	}
+	var data []models.Data
+	for _, e := range elements {
+		tds := e.ChildTexts(t.cell)
+		if len(tds) >= minParts {
+			data = append(data, models.Data{
+				Name:  tds[0],
+				Value: tds[1],
+			})
+		}
+	}
+	return data, nil
+}
+
+// equalsExtractor reads name=value pairs from element text.
+type equalsExtractor struct {
+	row string
+}
+
+func newEqualsExtractor(src config.Source) (Extractor, error) {
+	return equalsExtractor{row: orDefault(src.Selectors.Row, defaultEqualsRow)}, nil
+}
+
+func (eq equalsExtractor) Extract(resp *colly.Response) ([]models.Data, error) {
+	elements, err := htmlElements(resp, eq.row)
+	if err != nil {
+		return nil, err
+	}
+	var data []models.Data
+	for _, e := range elements {
+		tds := strings.Split(e.Text, "=")
+		if len(tds) >= minParts {
+			data = append(data, models.Data{
+				Name:  tds[0],
+				Value: tds[1],
+			})
+		}
+	}
+	return data, nil
+}
+
+// cssExtractor reads names and values through user-defined selectors.
+type cssExtractor struct {
+	sel config.Selectors
+}
+
+func newCSSExtractor(src config.Source) (Extractor, error) {
+	if src.Selectors.Row == "" {
+		return nil, fmt.Errorf("%s extractor requires a row selector", config.ModeCSS)
+	}
+	return cssExtractor{sel: src.Selectors}, nil
+}
+
+func (c cssExtractor) Extract(resp *colly.Response) ([]models.Data, error) {
+	elements, err := htmlElements(resp, c.sel.Row)
+	if err != nil {
+		return nil, err
+	}
+	var data []models.Data
+	for _, e := range elements {
+		name, okName := selectText(e, c.sel.Name, c.sel.NameAttr)
+		value, okValue := selectText(e, c.sel.Value, c.sel.ValueAttr)
+		if !okName || !okValue {
+			continue
+		}
+		data = append(data, models.Data{Name: name, Value: value})
+	}
+	return data, nil
+}
+
+// selectText returns the text or attribute of the first element matching
+// selector within e, or of e itself when selector is empty. The second
+// return value reports whether a matching element was found.
+func selectText(e *colly.HTMLElement, selector, attr string) (string, bool) {
+	if selector == "" {
+		if attr != "" {
+			return strings.TrimSpace(e.Attr(attr)), true
+		}
+		return strings.TrimSpace(e.Text), true
+	}
+	match := e.DOM.Find(selector).First()
+	if match.Length() == 0 {
+		return "", false
+	}
+	if attr != "" {
+		v, _ := match.Attr(attr)
+		return strings.TrimSpace(v), true
+	}
+	return strings.TrimSpace(match.Text()), true
+}
@@ -0,0 +1,64 @@
+package scraper
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/gocolly/colly/v2"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+// Extractor turns a fetched response into data lines.
+type Extractor interface {
+	Extract(resp *colly.Response) ([]models.Data, error)
+}
+
+// Factory creates an Extractor configured for a single source.
+type Factory func(src config.Source) (Extractor, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		config.ModeEquals: newEqualsExtractor,
+		config.ModeTable:  newTableExtractor,
+		config.ModeCSS:    newCSSExtractor,
+		config.ModeXPath:  newXPathExtractor,
+		config.ModeJSON:   newJSONExtractor,
+		config.ModeRegex:  newRegexExtractor,
+	}
+)
+
+// Register adds an extractor under the given mode name, replacing any
+// extractor previously registered under the same name.
+func Register(mode string, f Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[mode] = f
+	config.RegisterMode(mode)
+}
+
+// Lookup returns a new extractor for the mode of src.
+func Lookup(src config.Source) (Extractor, error) {
+	registryMu.RLock()
+	f, ok := registry[src.Mode]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown extractor %q", src.Mode)
+	}
+	return f(src)
+}
+
+// Modes returns the names of all registered extractors in sorted order.
+func Modes() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	modes := make([]string, 0, len(registry))
+	for m := range registry {
+		modes = append(modes, m)
+	}
+	sort.Strings(modes)
+	return modes
+}
@@ -0,0 +1,77 @@
+package scraper
+
+import (
+	"sync"
+	"time"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+// Holder remembers the last successful data of each source, by its ID, so
+// that a failed scrape can reuse it instead of dropping the source's lines,
+// which would shift every following line in the output.
+type Holder struct {
+	mu   sync.Mutex
+	last map[string]heldData
+	now  func() time.Time
+}
+
+type heldData struct {
+	data []models.Data
+	at   time.Time
+}
+
+func NewHolder() *Holder {
+	return &Holder{last: make(map[string]heldData), now: time.Now}
+}
+
+// Apply records res when it produced data. When res failed or came back
+// empty and its source has hold_last_good set, Apply substitutes the held
+// data and marks res stale, unless the held data is older than the source's
+// max_staleness.
+func (h *Holder) Apply(res *Result) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	id := res.Source.ID()
+	if res.Err == nil && len(res.Data) > 0 {
+		h.last[id] = heldData{data: res.Data, at: now}
+		return
+	}
+	if !res.Source.HoldLastGood {
+		return
+	}
+	held, ok := h.last[id]
+	if !ok {
+		return
+	}
+	age := now.Sub(held.at)
+	if limit := res.Source.MaxStaleness; limit > 0 && age > time.Duration(limit)*time.Millisecond {
+		return
+	}
+	res.Data = held.data
+	res.Stale = true
+	res.StaleFor = age
+}
+
+// Prune drops the held data of every source not in sources.
+func (h *Holder) Prune(sources []config.Source) {
+	keep := sourceIDs(sources)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id := range h.last {
+		if !keep[id] {
+			delete(h.last, id)
+		}
+	}
+}
+
+// sourceIDs returns the set of IDs of sources.
+func sourceIDs(sources []config.Source) map[string]bool {
+	ids := make(map[string]bool, len(sources))
+	for i := range sources {
+		ids[sources[i].ID()] = true
+	}
+	return ids
+}
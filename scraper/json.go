@@ -0,0 +1,92 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+	"github.com/batijo/poll-scraper/utils/jsonpath"
+)
+
+// jsonExtractor maps JSON values to data lines through JSONPath-style paths.
+// With a row path, name and value are evaluated relative to each selected
+// item; otherwise they are evaluated on the whole document and paired by
+// position.
+type jsonExtractor struct {
+	row, name, value *jsonpath.Path
+}
+
+func newJSONExtractor(src config.Source) (Extractor, error) {
+	j := jsonExtractor{}
+	var err error
+	if src.Selectors.Row != "" {
+		if j.row, err = jsonpath.Compile(src.Selectors.Row); err != nil {
+			return nil, fmt.Errorf("invalid row path: %w", err)
+		}
+	}
+	if j.name, err = jsonpath.Compile(src.Selectors.Name); err != nil {
+		return nil, fmt.Errorf("invalid name path: %w", err)
+	}
+	if j.value, err = jsonpath.Compile(src.Selectors.Value); err != nil {
+		return nil, fmt.Errorf("invalid value path: %w", err)
+	}
+	return j, nil
+}
+
+func (j jsonExtractor) Extract(resp *colly.Response) ([]models.Data, error) {
+	decoder := json.NewDecoder(bytes.NewReader(resp.Body))
+	decoder.UseNumber()
+	var doc any
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	var data []models.Data
+	if j.row == nil {
+		names := j.name.Eval(doc)
+		values := j.value.Eval(doc)
+		if len(names) != len(values) {
+			return nil, fmt.Errorf("name and value paths matched %d and %d values", len(names), len(values))
+		}
+		for i := range names {
+			data = append(data, models.Data{Name: jsonText(names[i]), Value: jsonText(values[i])})
+		}
+		return data, nil
+	}
+
+	for _, item := range j.row.Eval(doc) {
+		names := j.name.Eval(item)
+		values := j.value.Eval(item)
+		if len(names) == 0 || len(values) == 0 {
+			continue
+		}
+		data = append(data, models.Data{Name: jsonText(names[0]), Value: jsonText(values[0])})
+	}
+	return data, nil
+}
+
+// jsonText formats a decoded JSON value as a data line string.
+func jsonText(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(t)
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Sprint(t)
+		}
+		return string(b)
+	}
+}
@@ -0,0 +1,93 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/batijo/poll-scraper/config"
+)
+
+const defaultLoginTimeout = 10 * time.Second
+
+var errSessionRejected = errors.New("still redirected to the login page after logging in")
+
+// ensureSession logs col in for src unless it already holds a session or
+// force is set.
+func (s *Scraper) ensureSession(col *collector, src config.Source, force bool) error {
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	if col.loggedIn && !force {
+		return nil
+	}
+	col.loggedIn = false
+	if err := s.login(col, src); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	col.loggedIn = true
+	return nil
+}
+
+// login posts the login form of src, storing the session cookies in the
+// collector's jar. Credentials are never logged.
+func (s *Scraper) login(col *collector, src config.Source) error {
+	l := src.Login
+	form := url.Values{}
+	for k, v := range l.Fields {
+		form.Set(k, v)
+	}
+	form.Set(l.UsernameField, l.Username)
+	form.Set(l.PasswordField, l.Password)
+
+	timeout := col.timeout
+	if timeout <= 0 {
+		timeout = defaultLoginTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if s.settings.UserAgent != "" {
+		req.Header.Set("User-Agent", s.settings.UserAgent)
+	}
+	applySource(src, &req.Header)
+
+	slog.Debug("logging in", "url", src.URL, "login_url", l.URL, "headers", maskedHeader(req.Header))
+	client := &http.Client{Transport: col.transport, Jar: col.jar}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := resp.Body.Close(); cerr != nil {
+			slog.Debug("failed to close login response body", "err", cerr)
+		}
+	}()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	slog.Info("logged in", "url", src.URL)
+	return nil
+}
+
+// isLoginPage reports whether u points at the login form of l, which is
+// where protected pages redirect once the session has expired.
+func isLoginPage(l *config.Login, u *url.URL) bool {
+	login, err := url.Parse(l.URL)
+	if err != nil || u == nil {
+		return false
+	}
+	return strings.EqualFold(login.Host, u.Host) && strings.TrimSuffix(login.Path, "/") == strings.TrimSuffix(u.Path, "/")
+}
@@ -0,0 +1,88 @@
+package scraper
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/batijo/poll-scraper/config"
+)
+
+// Options controls a batch of scrapes.
+type Options struct {
+	// Workers is the maximum number of sources scraped at once; zero means
+	// all of them.
+	Workers int
+	// Cycle is the writer cycle number, used in log output.
+	Cycle int
+	// Hold, when set, substitutes last good data for failed sources.
+	Hold *Holder
+	// Cache, when set, makes requests conditional and reuses the data of
+	// unchanged pages.
+	Cache *Cache
+	// Context, when set, cancels the scrape: sources not started yet are
+	// skipped and pending retries are abandoned.
+	Context context.Context
+}
+
+// ScrapeSources scrapes sources with the default scraper.
+func ScrapeSources(sources []config.Source, opts Options) []Result {
+	return defaultScraper.ScrapeSources(sources, opts)
+}
+
+// ScrapeSources scrapes sources with at most opts.Workers requests in flight
+// and returns the results in the same order as sources.
+func (s *Scraper) ScrapeSources(sources []config.Source, opts Options) []Result {
+	results := make([]Result, len(sources))
+	if len(sources) == 0 {
+		return results
+	}
+	workers := opts.Workers
+	if workers <= 0 || workers > len(sources) {
+		workers = len(sources)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				src := sources[i]
+				results[i] = s.scrape(src, opts)
+				if opts.Cache != nil {
+					results[i].CacheHitRate = opts.Cache.HitRate(src.ID())
+				}
+				slog.Debug("scraped URL", "url", src.URL, "label", src.Label, "mode", src.Mode, "cycle", opts.Cycle,
+					"lines", len(results[i].Data), "status", results[i].StatusCode, "attempts", results[i].Attempts,
+					"not_modified", results[i].NotModified, "cache_hit_rate", results[i].CacheHitRate,
+					"took", results[i].Latency.Round(time.Millisecond))
+				if opts.Hold == nil {
+					continue
+				}
+				opts.Hold.Apply(&results[i])
+				if results[i].Stale {
+					slog.Warn("holding last good data", "url", src.URL, "cycle", opts.Cycle,
+						"lines", len(results[i].Data), "age", results[i].StaleFor.Round(time.Millisecond))
+				}
+			}
+		}()
+	}
+	for i := range sources {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	// Hold and Cache serve one list of sources, so entries of sources that
+	// were removed from it are dropped
+	if opts.Hold != nil {
+		opts.Hold.Prune(sources)
+	}
+	if opts.Cache != nil {
+		opts.Cache.Prune(sources)
+		slog.Debug("conditional request cache", "cycle", opts.Cycle, "hit_rate", opts.Cache.TotalHitRate())
+	}
+	return results
+}
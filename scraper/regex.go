@@ -0,0 +1,64 @@
+package scraper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+// regexExtractor reads every match of a pattern with name and value capture
+// groups. The pattern runs on the text of each row element, or on the whole
+// response body when no row selector is set.
+type regexExtractor struct {
+	row         string
+	re          *regexp.Regexp
+	name, value int
+}
+
+func newRegexExtractor(src config.Source) (Extractor, error) {
+	re, err := regexp.Compile(src.Selectors.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	x := regexExtractor{
+		row:   src.Selectors.Row,
+		re:    re,
+		name:  re.SubexpIndex("name"),
+		value: re.SubexpIndex("value"),
+	}
+	if x.name < 0 || x.value < 0 {
+		return nil, fmt.Errorf("pattern must contain name and value groups")
+	}
+	return x, nil
+}
+
+func (x regexExtractor) Extract(resp *colly.Response) ([]models.Data, error) {
+	if x.row == "" {
+		return x.match(string(resp.Body)), nil
+	}
+	elements, err := htmlElements(resp, x.row)
+	if err != nil {
+		return nil, err
+	}
+	var data []models.Data
+	for _, e := range elements {
+		data = append(data, x.match(e.Text)...)
+	}
+	return data, nil
+}
+
+func (x regexExtractor) match(text string) []models.Data {
+	var data []models.Data
+	for _, m := range x.re.FindAllStringSubmatch(text, -1) {
+		data = append(data, models.Data{
+			Name:  strings.TrimSpace(m[x.name]),
+			Value: strings.TrimSpace(m[x.value]),
+		})
+	}
+	return data
+}
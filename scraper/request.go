@@ -0,0 +1,49 @@
+package scraper
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/utils"
+)
+
+// applySource sets the User-Agent, headers and cookies configured for src.
+func applySource(src config.Source, h *http.Header) {
+	if src.UserAgent != "" {
+		h.Set("User-Agent", src.UserAgent)
+	}
+	for k, v := range src.Headers {
+		h.Set(k, v)
+	}
+	if len(src.Cookies) == 0 {
+		return
+	}
+	cookies := make([]string, 0, len(src.Cookies)+1)
+	if existing := h.Get("Cookie"); existing != "" {
+		cookies = append(cookies, existing)
+	}
+	for _, c := range src.Cookies {
+		cookies = append(cookies, (&http.Cookie{Name: c.Name, Value: c.Value}).String())
+	}
+	h.Set("Cookie", strings.Join(cookies, "; "))
+}
+
+// maskedHeader logs header names with their values masked, so credentials
+// never reach the log file or the UI.
+type maskedHeader http.Header
+
+func (h maskedHeader) LogValue() slog.Value {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	attrs := make([]slog.Attr, 0, len(names))
+	for _, name := range names {
+		attrs = append(attrs, slog.String(name, utils.Mask(strings.Join(h[name], ", "))))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,104 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+// ErrorKind classifies why a scrape failed.
+type ErrorKind string
+
+const (
+	KindConfig  ErrorKind = "config"
+	KindNetwork ErrorKind = "network"
+	KindTimeout ErrorKind = "timeout"
+	KindHTTP    ErrorKind = "http"
+	KindParse   ErrorKind = "parse"
+	KindAuth    ErrorKind = "auth"
+)
+
+// ScrapeError is a failed scrape with its cause classified.
+type ScrapeError struct {
+	Kind       ErrorKind
+	StatusCode int
+	Err        error
+}
+
+func (e *ScrapeError) Error() string {
+	if e.Kind == KindHTTP {
+		return fmt.Sprintf("%s error: status %d: %v", e.Kind, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("%s error: %v", e.Kind, e.Err)
+}
+
+func (e *ScrapeError) Unwrap() error {
+	return e.Err
+}
+
+// Result is the outcome of scraping a single source.
+type Result struct {
+	Source     config.Source
+	Data       []models.Data
+	StatusCode int
+	Latency    time.Duration
+	Bytes      int
+	Charset    string
+	Attempts   int
+	Err        error
+	// Stale is set when Data was held over from an earlier successful
+	// scrape, StaleFor being its age.
+	Stale    bool
+	StaleFor time.Duration
+	// NotModified is set when the server answered 304 and Data came from
+	// the cache. CacheHitRate is the share of the URL's responses served
+	// from the cache so far.
+	NotModified  bool
+	CacheHitRate float64
+}
+
+// Status converts the result to the status reported to the UI and API.
+func (r *Result) Status() models.URLStatus {
+	status := models.URLStatus{
+		URL:          r.Source.URL,
+		Label:        r.Source.Label,
+		HasData:      len(r.Data) > 0,
+		LineCount:    len(r.Data),
+		DurationMs:   r.Latency.Milliseconds(),
+		StatusCode:   r.StatusCode,
+		Bytes:        r.Bytes,
+		Charset:      r.Charset,
+		Attempts:     r.Attempts,
+		Stale:        r.Stale,
+		StaleMs:      r.StaleFor.Milliseconds(),
+		NotModified:  r.NotModified,
+		CacheHitRate: r.CacheHitRate,
+	}
+	if r.Err != nil {
+		status.ErrorKind = string(KindNetwork)
+		var se *ScrapeError
+		if errors.As(r.Err, &se) {
+			status.ErrorKind = string(se.Kind)
+		}
+		status.Message = r.Err.Error()
+	}
+	return status
+}
+
+// classifyRequestError wraps an error returned while fetching a page.
+func classifyRequestError(err error, statusCode int) *ScrapeError {
+	var netErr net.Error
+	switch {
+	case statusCode >= 300:
+		return &ScrapeError{Kind: KindHTTP, StatusCode: statusCode, Err: err}
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return &ScrapeError{Kind: KindTimeout, Err: err}
+	default:
+		return &ScrapeError{Kind: KindNetwork, Err: err}
+	}
+}
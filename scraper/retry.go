@@ -0,0 +1,86 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/batijo/poll-scraper/config"
+)
+
+const (
+	defaultRetryBackoff = 250 * time.Millisecond
+	maxRetryBackoff     = 10 * time.Second
+)
+
+// scrape fetches src, retrying transient failures up to src.Retries times
+// with exponential backoff until opts.Context is done.
+func (s *Scraper) scrape(src config.Source, opts Options) Result {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{Source: src, Err: err}
+	}
+	var res Result
+	for attempt := 0; ; attempt++ {
+		res = s.fetch(src, opts.Cache)
+		res.Attempts = attempt + 1
+		if res.Err == nil || attempt >= src.Retries || !retryable(res.Err) {
+			return res
+		}
+		wait := backoff(src.RetryBackoff, attempt)
+		slog.Warn("retrying URL",
+			"url", src.URL,
+			"cycle", opts.Cycle,
+			"attempt", attempt+2,
+			"max_attempts", src.Retries+1,
+			"backoff", wait,
+			"err", res.Err,
+		)
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res
+		case <-timer.C:
+		}
+	}
+}
+
+// retryable reports whether err is a failure that may succeed on retry:
+// network errors, timeouts, 5xx and 429 responses.
+func retryable(err error) bool {
+	var se *ScrapeError
+	if !errors.As(err, &se) {
+		return false
+	}
+	switch se.Kind {
+	case KindNetwork, KindTimeout:
+		return true
+	case KindHTTP:
+		return se.StatusCode >= http.StatusInternalServerError || se.StatusCode == http.StatusTooManyRequests
+	case KindConfig, KindParse, KindAuth:
+		return false
+	}
+	return false
+}
+
+// backoff returns the wait before the retry following attempt, doubling
+// base (in milliseconds) each time up to maxRetryBackoff.
+func backoff(base, attempt int) time.Duration {
+	wait := defaultRetryBackoff
+	if base > 0 {
+		wait = time.Duration(base) * time.Millisecond
+	}
+	for range attempt {
+		wait *= 2
+		if wait >= maxRetryBackoff {
+			return maxRetryBackoff
+		}
+	}
+	return wait
+}
@@ -1,81 +1,159 @@
 package scraper
 
 import (
-	"fmt"
 	"log/slog"
-	"strings"
+	"net/http"
+	"time"
 
 	"github.com/gocolly/colly/v2"
 
+	"github.com/batijo/poll-scraper/config"
 	"github.com/batijo/poll-scraper/models"
 )
 
-const minParts = 2
+const (
+	minParts = 2
+	visitKey = "visit"
+)
+
+// visit is the state of a single request, carried in its colly context.
+type visit struct {
+	src   config.Source
+	id    string
+	ext   Extractor
+	cache *Cache
+	res   *Result
+	// expired is set when the response shows that the login session is
+	// no longer valid.
+	expired bool
+}
+
+func visitFrom(ctx *colly.Context) *visit {
+	v, _ := ctx.GetAny(visitKey).(*visit)
+	return v
+}
 
-func ScrapeURL(link string, withEq bool) []models.Data {
-	if withEq {
-		return ScrapeWithEquals(link)
+func (v *visit) response(r *colly.Response) {
+	v.res.StatusCode = r.StatusCode
+	v.res.Bytes = len(r.Body)
+	if v.src.Login != nil && isLoginPage(v.src.Login, r.Request.URL) {
+		v.expired = true
+		return
+	}
+	if v.src.Charset != "" {
+		v.res.Charset = v.src.Charset
+	} else {
+		r.Body, v.res.Charset = decodeBody(r.Body, r.Headers.Get("Content-Type"))
+	}
+	d, err := v.ext.Extract(r)
+	if err != nil {
+		v.res.Err = &ScrapeError{Kind: KindParse, StatusCode: r.StatusCode, Err: err}
+		return
+	}
+	sanitizeUTF8(d)
+	models.NormalizeData(d, v.src.DecimalSeparator)
+	models.AssignKeys(d, v.src.URL)
+	v.res.Data = d
+	if v.cache != nil {
+		v.cache.store(v.id, r.Headers, d)
 	}
-	return ScrapeWithoutEquals(link)
 }
 
-func ScrapeAll(links []string, withEq bool) []models.Data {
-	slog.Debug("scraping all URLs", "count", len(links), "with_eq", withEq)
-	var (
-		nData []models.Data
-		data  []models.Data
-	)
-	for _, l := range links {
-		if withEq {
-			nData = ScrapeWithEquals(l)
-		} else {
-			nData = ScrapeWithoutEquals(l)
+func (v *visit) error(r *colly.Response, err error) {
+	v.res.StatusCode = r.StatusCode
+	v.res.Bytes = len(r.Body)
+	if r.StatusCode == http.StatusNotModified && v.cache != nil {
+		if d, ok := v.cache.hit(v.id); ok {
+			v.res.Data = d
+			v.res.NotModified = true
+			return
 		}
-		slog.Debug("scraped URL", "url", l, "lines", len(nData))
-		data = append(data, nData...)
 	}
-	slog.Debug("scraping complete", "total_lines", len(data))
-	return data
+	if v.src.Login != nil && (r.StatusCode == http.StatusUnauthorized || r.StatusCode == http.StatusForbidden) {
+		v.expired = true
+	}
+	v.res.Err = classifyRequestError(err, r.StatusCode)
 }
 
-func ScrapeWithoutEquals(link string) []models.Data {
-	var data []models.Data
-	c := colly.NewCollector()
-	c.OnHTML("tbody tr", func(e *colly.HTMLElement) {
-		tds := e.ChildTexts(".pdg")
-		if len(tds) >= minParts {
-			data = append(data, models.Data{
-				Name:  tds[0],
-				Value: tds[1],
-			})
-		}
-	})
-	c.OnError(func(r *colly.Response, err error) {
-		slog.Error(fmt.Sprint("Request URL:", r.Request.URL, "failed"), "err", err)
-	})
-	if err := c.Visit(link); err != nil {
-		slog.Error("failed to visit link", "link", link, "err", err)
+// ScrapeSource fetches src and extracts its data lines, retrying transient
+// failures. Failures are reported through Result.Err rather than logged.
+func (s *Scraper) ScrapeSource(src config.Source) Result {
+	return s.scrape(src, Options{})
+}
+
+// fetch makes a single attempt at scraping src. With a cache, the request is
+// conditional and a 304 response reuses the cached data. Sources with a
+// login are logged in first and once more when their session has expired.
+func (s *Scraper) fetch(src config.Source, cache *Cache) Result {
+	ext, err := Lookup(src)
+	if err != nil {
+		return Result{Source: src, Err: &ScrapeError{Kind: KindConfig, Err: err}}
 	}
-	return data
+	col := s.collector(src)
+	if src.Login == nil {
+		res, _ := s.request(col, src, ext, cache)
+		return res
+	}
+	if err := s.ensureSession(col, src, false); err != nil {
+		return Result{Source: src, Err: &ScrapeError{Kind: KindAuth, Err: err}}
+	}
+	res, expired := s.request(col, src, ext, cache)
+	if !expired {
+		return res
+	}
+	slog.Info("session expired, logging in again", "url", src.URL)
+	if err := s.ensureSession(col, src, true); err != nil {
+		return Result{Source: src, Err: &ScrapeError{Kind: KindAuth, Err: err}}
+	}
+	res, expired = s.request(col, src, ext, cache)
+	if expired {
+		res.Data = nil
+		res.Err = &ScrapeError{Kind: KindAuth, StatusCode: res.StatusCode, Err: errSessionRejected}
+	}
+	return res
+}
+
+// request visits src once and reports whether its session has expired.
+func (s *Scraper) request(col *collector, src config.Source, ext Extractor, cache *Cache) (Result, bool) {
+	res := Result{Source: src}
+	v := &visit{src: src, id: src.ID(), ext: ext, cache: cache, res: &res}
+	ctx := colly.NewContext()
+	ctx.Put(visitKey, v)
+	start := time.Now()
+	err := col.c.Request(http.MethodGet, src.URL, nil, ctx, nil)
+	if err != nil && res.Err == nil && !res.NotModified {
+		res.Err = classifyRequestError(err, res.StatusCode)
+	}
+	res.Latency = time.Since(start)
+	return res, v.expired
+}
+
+// ScrapeSource scrapes src with the default scraper.
+func ScrapeSource(src config.Source) Result {
+	return defaultScraper.ScrapeSource(src)
+}
+
+func ScrapeURL(link, mode string) Result {
+	return ScrapeSource(config.Source{URL: link, Mode: mode, Enabled: true})
 }
 
-func ScrapeWithEquals(link string) []models.Data {
+func ScrapeAll(sources []config.Source, workers int) []models.Data {
+	slog.Debug("scraping all URLs", "count", len(sources), "workers", workers)
 	var data []models.Data
-	c := colly.NewCollector()
-	c.OnHTML("p", func(e *colly.HTMLElement) {
-		tds := strings.Split(e.Text, "=")
-		if len(tds) >= minParts {
-			data = append(data, models.Data{
-				Name:  tds[0],
-				Value: tds[1],
-			})
+	for _, r := range ScrapeSources(sources, Options{Workers: workers}) {
+		if r.Err != nil {
+			slog.Error("failed to scrape URL", "url", r.Source.URL, "err", r.Err)
 		}
-	})
-	c.OnError(func(r *colly.Response, err error) {
-		slog.Error(fmt.Sprint("Request URL:", r.Request.URL, "failed"), "err", err)
-	})
-	if err := c.Visit(link); err != nil {
-		slog.Error("failed to visit link", "link", link, "err", err)
+		data = append(data, r.Data...)
 	}
+	slog.Debug("scraping complete", "total_lines", len(data))
 	return data
 }
+
+func ScrapeWithoutEquals(link string) Result {
+	return ScrapeURL(link, config.ModeTable)
+}
+
+func ScrapeWithEquals(link string) Result {
+	return ScrapeURL(link, config.ModeEquals)
+}
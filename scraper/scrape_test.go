@@ -1,9 +1,23 @@
 package scraper
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
 )
 
 func TestScrapeWithoutEquals(t *testing.T) {
@@ -18,7 +32,7 @@ func TestScrapeWithoutEquals(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	data := ScrapeWithoutEquals(ts.URL)
+	data := ScrapeWithoutEquals(ts.URL).Data
 
 	if len(data) != 2 {
 		t.Fatalf("got %d items, want 2", len(data))
@@ -35,7 +49,7 @@ func TestScrapeWithEquals(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	data := ScrapeWithEquals(ts.URL)
+	data := ScrapeWithEquals(ts.URL).Data
 
 	if len(data) != 2 {
 		t.Fatalf("got %d items, want 2", len(data))
@@ -52,7 +66,7 @@ func TestScrapeAll_WithEquals(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	data := ScrapeAll([]string{ts.URL}, true)
+	data := ScrapeAll([]config.Source{{URL: ts.URL, Mode: config.ModeEquals, Enabled: true}}, 0)
 
 	if len(data) != 1 {
 		t.Fatalf("got %d items, want 1", len(data))
@@ -73,7 +87,7 @@ func TestScrapeAll_WithoutEquals(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	data := ScrapeAll([]string{ts.URL}, false)
+	data := ScrapeAll([]config.Source{{URL: ts.URL, Mode: config.ModeTable, Enabled: true}}, 0)
 
 	if len(data) != 1 {
 		t.Fatalf("got %d items, want 1", len(data))
@@ -82,3 +96,945 @@ func TestScrapeAll_WithoutEquals(t *testing.T) {
 		t.Errorf("data[0] = {%q, %q}, want {%q, %q}", data[0].Name, data[0].Value, "Item1", "100")
 	}
 }
+
+func TestScrapeAll_PerSourceMode(t *testing.T) {
+	eq := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><p>Key=Val</p></body></html>`))
+	}))
+	defer eq.Close()
+	table := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`
+			<html><body><table><tbody>
+				<tr><td class="pdg">Item1</td><td class="pdg">100</td></tr>
+			</tbody></table></body></html>
+		`))
+	}))
+	defer table.Close()
+
+	cfg := &config.Config{
+		Sources: []config.Source{
+			{URL: eq.URL, Mode: config.ModeEquals, Enabled: true},
+			{URL: table.URL, Enabled: true},
+			{URL: eq.URL, Enabled: false},
+		},
+		Extractor: config.ModeTable,
+	}
+	data := ScrapeAll(cfg.ActiveSources(), 2)
+
+	if len(data) != 2 {
+		t.Fatalf("got %d items, want 2", len(data))
+	}
+	if data[0].Name != "Key" || data[1].Name != "Item1" {
+		t.Errorf("got [%s, %s], want [Key, Item1]", data[0].Name, data[1].Name)
+	}
+}
+
+func TestScrapeSource_SendsHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><p>Token=` + r.Header.Get("X-Token") + `</p></body></html>`))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:     ts.URL,
+		Mode:    config.ModeEquals,
+		Enabled: true,
+		Headers: map[string]string{"X-Token": "secret"},
+	}).Data
+
+	if len(data) != 1 || data[0].Value != "secret" {
+		t.Errorf("got %v, want [{Token secret}]", data)
+	}
+}
+
+type staticExtractor struct{}
+
+func (staticExtractor) Extract(*colly.Response) ([]models.Data, error) {
+	return []models.Data{{Name: "static", Value: "1"}}, nil
+}
+
+func TestScrapeSource_SendsCookiesAndUserAgent(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := r.Cookie("session")
+		lang, _ := r.Cookie("lang")
+		fmt.Fprintf(w, "<p>Agent=%s</p><p>Session=%s</p><p>Lang=%s</p>", r.UserAgent(), session.Value, lang.Value)
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:       ts.URL,
+		Mode:      config.ModeEquals,
+		Enabled:   true,
+		UserAgent: "Mozilla/5.0",
+		Cookies:   []config.Cookie{{Name: "session", Value: "abc123"}, {Name: "lang", Value: "lt"}},
+	}).Data
+
+	want := []models.Data{{Name: "Agent", Value: "Mozilla/5.0"}, {Name: "Session", Value: "abc123"}, {Name: "Lang", Value: "lt"}}
+	if len(data) != len(want) {
+		t.Fatalf("got %v, want %v", data, want)
+	}
+	for i := range want {
+		if data[i].Name != want[i].Name || data[i].Value != want[i].Value {
+			t.Errorf("line %d = %v, want %v", i, data[i], want[i])
+		}
+	}
+}
+
+func TestMaskedHeader_HidesValues(t *testing.T) {
+	h := http.Header{"Cookie": {"session=abc123"}, "X-Token": {"secret"}}
+
+	got := slog.AnyValue(maskedHeader(h)).Resolve().String()
+
+	if strings.Contains(got, "abc123") || strings.Contains(got, "secret") {
+		t.Errorf("masked header %q leaks a value", got)
+	}
+	if !strings.Contains(got, "X-Token") {
+		t.Errorf("masked header %q should keep header names", got)
+	}
+}
+
+func TestRegister_CustomExtractor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`anything`))
+	}))
+	defer ts.Close()
+
+	Register("static", func(config.Source) (Extractor, error) { return staticExtractor{}, nil })
+
+	data := ScrapeURL(ts.URL, "static").Data
+
+	if len(data) != 1 || data[0].Name != "static" {
+		t.Errorf("got %v, want [{static 1}]", data)
+	}
+}
+
+func TestLookup_UnknownMode(t *testing.T) {
+	if _, err := Lookup(config.Source{Mode: "missing"}); err == nil {
+		t.Error("expected error for unknown extractor")
+	}
+}
+
+func TestScrapeSource_CSSSelectors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`
+			<html><body><ul>
+				<li class="opt"><span class="title"> Item1 </span><b data-votes="100">100 votes</b></li>
+				<li class="opt"><span class="title">Item2</span><b data-votes="200">200 votes</b></li>
+				<li class="opt"><span class="title">Broken</span></li>
+			</ul></body></html>
+		`))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:     ts.URL,
+		Mode:    config.ModeCSS,
+		Enabled: true,
+		Selectors: config.Selectors{
+			Row:       "li.opt",
+			Name:      ".title",
+			Value:     "b",
+			ValueAttr: "data-votes",
+		},
+	}).Data
+
+	if len(data) != 2 {
+		t.Fatalf("got %d items, want 2", len(data))
+	}
+	if data[0].Name != "Item1" || data[0].Value != "100" {
+		t.Errorf("data[0] = {%q, %q}, want {%q, %q}", data[0].Name, data[0].Value, "Item1", "100")
+	}
+}
+
+func TestScrapeSource_TableSelectorOverride(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`
+			<html><body><table class="poll">
+				<tr><td class="cell">Item1</td><td class="cell">100</td></tr>
+			</table></body></html>
+		`))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:       ts.URL,
+		Mode:      config.ModeTable,
+		Enabled:   true,
+		Selectors: config.Selectors{Row: "table.poll tr", Cell: "td.cell"},
+	}).Data
+
+	if len(data) != 1 || data[0].Name != "Item1" || data[0].Value != "100" {
+		t.Errorf("got %v, want [{Item1 100}]", data)
+	}
+}
+
+func TestScrapeSource_XPathRows(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`
+			<html><body><table>
+				<tr><th>Item1</th><td data-v="100">x</td></tr>
+				<tr><th>Item2</th><td data-v="200">y</td></tr>
+			</table></body></html>
+		`))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:       ts.URL,
+		Mode:      config.ModeXPath,
+		Enabled:   true,
+		Selectors: config.Selectors{Row: "//tr", Name: "./th", Value: "./td/@data-v"},
+	}).Data
+
+	if len(data) != 2 {
+		t.Fatalf("got %d items, want 2", len(data))
+	}
+	if data[1].Name != "Item2" || data[1].Value != "200" {
+		t.Errorf("data[1] = {%q, %q}, want {%q, %q}", data[1].Name, data[1].Value, "Item2", "200")
+	}
+}
+
+func TestScrapeSource_XPathWithoutRows(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body><h3>A</h3><span>1</span><h3>B</h3><span>2</span></body></html>`))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:       ts.URL,
+		Mode:      config.ModeXPath,
+		Enabled:   true,
+		Selectors: config.Selectors{Name: "//h3", Value: "//span"},
+	}).Data
+
+	if len(data) != 2 || data[0].Name != "A" || data[1].Value != "2" {
+		t.Errorf("got %v, want [{A 1} {B 2}]", data)
+	}
+}
+
+func TestScrapeSource_JSONRows(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"poll": {"options": [
+			{"title": " Item1 ", "stats": {"votes": 100}},
+			{"title": "Item2", "stats": {"votes": 200}},
+			{"title": "NoVotes"}
+		]}}`))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:       ts.URL,
+		Mode:      config.ModeJSON,
+		Enabled:   true,
+		Selectors: config.Selectors{Row: "$.poll.options[*]", Name: "title", Value: "stats.votes"},
+	}).Data
+
+	if len(data) != 2 {
+		t.Fatalf("got %d items, want 2", len(data))
+	}
+	if data[0].Name != "Item1" || data[0].Value != "100" {
+		t.Errorf("data[0] = {%q, %q}, want {%q, %q}", data[0].Name, data[0].Value, "Item1", "100")
+	}
+}
+
+func TestScrapeSource_JSONWithoutRows(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"labels": ["A", "B"], "counts": [1, 2.5]}`))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:       ts.URL,
+		Mode:      config.ModeJSON,
+		Enabled:   true,
+		Selectors: config.Selectors{Name: "labels[*]", Value: "$['counts'][*]"},
+	}).Data
+
+	if len(data) != 2 || data[0].Name != "A" || data[1].Value != "2.5" {
+		t.Errorf("got %v, want [{A 1} {B 2.5}]", data)
+	}
+}
+
+func TestScrapeSource_RegexOnElements(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(`<html><body>
+			<p> Formula = a=b </p>
+			<p>A: 1; B: 2</p>
+		</body></html>`))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:     ts.URL,
+		Mode:    config.ModeRegex,
+		Enabled: true,
+		Selectors: config.Selectors{
+			Row:     "p",
+			Pattern: `(?P<name>[^=:;]+)[=:](?P<value>[^;]+)`,
+		},
+	}).Data
+
+	if len(data) != 3 {
+		t.Fatalf("got %d items, want 3: %v", len(data), data)
+	}
+	if data[0].Name != "Formula" || data[0].Value != "a=b" {
+		t.Errorf("data[0] = {%q, %q}, want {%q, %q}", data[0].Name, data[0].Value, "Formula", "a=b")
+	}
+	if data[2].Name != "B" || data[2].Value != "2" {
+		t.Errorf("data[2] = {%q, %q}, want {%q, %q}", data[2].Name, data[2].Value, "B", "2")
+	}
+}
+
+func TestScrapeSource_RegexOnBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("Item1 -> 100\nItem2 -> 200\n"))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{
+		URL:       ts.URL,
+		Mode:      config.ModeRegex,
+		Enabled:   true,
+		Selectors: config.Selectors{Pattern: `(?m)^(?P<name>.+?)->(?P<value>.+)$`},
+	}).Data
+
+	if len(data) != 2 || data[1].Name != "Item2" || data[1].Value != "200" {
+		t.Errorf("got %v, want [{Item1 100} {Item2 200}]", data)
+	}
+}
+
+func TestScrapeSources_PreservesOrderConcurrently(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, `<html><body><p>Item%s=1</p></body></html>`, r.URL.Query().Get("i"))
+	}))
+	defer ts.Close()
+
+	var sources []config.Source
+	for i := range 4 {
+		sources = append(sources, config.Source{URL: fmt.Sprintf("%s/?i=%d", ts.URL, i), Mode: config.ModeEquals, Enabled: true})
+	}
+
+	start := time.Now()
+	results := ScrapeSources(sources, Options{Workers: 4})
+	elapsed := time.Since(start)
+
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4", len(results))
+	}
+	for i, r := range results {
+		want := fmt.Sprintf("Item%d", i)
+		if len(r.Data) != 1 || r.Data[0].Name != want {
+			t.Errorf("results[%d].Data = %v, want [{%s 1}]", i, r.Data, want)
+		}
+	}
+	if results[0].Latency < delay {
+		t.Errorf("results[0].Latency = %v, want >= %v", results[0].Latency, delay)
+	}
+	if elapsed >= 2*delay {
+		t.Errorf("scraping took %v, want sources fetched in parallel", elapsed)
+	}
+}
+
+func TestScrapeSource_ReportsMetadata(t *testing.T) {
+	body := `<html><body><p>Key=Val</p></body></html>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	res := ScrapeURL(ts.URL, config.ModeEquals)
+
+	if res.Err != nil {
+		t.Fatalf("unexpected error: %v", res.Err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+	if res.Bytes != len(body) {
+		t.Errorf("Bytes = %d, want %d", res.Bytes, len(body))
+	}
+	if res.Latency <= 0 {
+		t.Error("Latency was not recorded")
+	}
+	if len(res.Data) != 1 || res.Data[0].Source != ts.URL || res.Data[0].Key != ts.URL+"#Key" {
+		t.Errorf("Data = %+v, want line keyed by source and name", res.Data)
+	}
+}
+
+func TestScrapeSource_HTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	res := ScrapeURL(ts.URL, config.ModeEquals)
+
+	var se *ScrapeError
+	if !errors.As(res.Err, &se) {
+		t.Fatalf("Err = %v, want *ScrapeError", res.Err)
+	}
+	if se.Kind != KindHTTP || se.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got kind %q status %d, want %q %d", se.Kind, se.StatusCode, KindHTTP, http.StatusServiceUnavailable)
+	}
+	status := res.Status()
+	if status.ErrorKind != string(KindHTTP) || status.StatusCode != http.StatusServiceUnavailable || status.Message == "" {
+		t.Errorf("Status() = %+v, want http error with status 503 and message", status)
+	}
+}
+
+func TestScrapeSource_NetworkError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := ts.URL
+	ts.Close()
+
+	res := ScrapeURL(url, config.ModeEquals)
+
+	var se *ScrapeError
+	if !errors.As(res.Err, &se) || se.Kind != KindNetwork {
+		t.Errorf("Err = %v, want network error", res.Err)
+	}
+}
+
+func TestScrapeSource_Timeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	res := ScrapeSource(config.Source{URL: ts.URL, Mode: config.ModeEquals, Enabled: true, Timeout: 20})
+
+	var se *ScrapeError
+	if !errors.As(res.Err, &se) || se.Kind != KindTimeout {
+		t.Errorf("Err = %v, want timeout error", res.Err)
+	}
+}
+
+func TestScrapeSource_ParseError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`not json`))
+	}))
+	defer ts.Close()
+
+	res := ScrapeSource(config.Source{
+		URL:       ts.URL,
+		Mode:      config.ModeJSON,
+		Enabled:   true,
+		Selectors: config.Selectors{Name: "name", Value: "value"},
+	})
+
+	var se *ScrapeError
+	if !errors.As(res.Err, &se) || se.Kind != KindParse {
+		t.Errorf("Err = %v, want parse error", res.Err)
+	}
+}
+
+func TestScrapeSource_RetriesTransientErrors(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`<p>Alice=100</p>`))
+	}))
+	defer ts.Close()
+
+	res := ScrapeSource(config.Source{URL: ts.URL, Mode: config.ModeEquals, Enabled: true, Retries: 3, RetryBackoff: 1})
+
+	if res.Err != nil {
+		t.Fatalf("unexpected error: %v", res.Err)
+	}
+	if res.Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", res.Attempts)
+	}
+	if len(res.Data) != 1 || res.Data[0].Value != "100" {
+		t.Errorf("Data = %+v, want one line with value 100", res.Data)
+	}
+}
+
+func TestScrapeSource_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	res := ScrapeSource(config.Source{URL: ts.URL, Mode: config.ModeEquals, Enabled: true, Retries: 3, RetryBackoff: 1})
+
+	if res.Err == nil {
+		t.Fatal("expected error for 404 response")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("server called %d times, want 1", got)
+	}
+}
+
+func TestScrapeSources_CancelAbandonsRetries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	src := config.Source{URL: ts.URL, Mode: config.ModeEquals, Enabled: true, Retries: 10, RetryBackoff: 5000}
+
+	start := time.Now()
+	res := New(Settings{}).ScrapeSources([]config.Source{src}, Options{Context: ctx})
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("scrape took %v after cancel, want it abandoned", elapsed)
+	}
+	if res[0].Err == nil || res[0].Attempts != 1 {
+		t.Errorf("result = %+v, want the failed first attempt", res[0])
+	}
+
+	res = New(Settings{}).ScrapeSources([]config.Source{src}, Options{Context: ctx})
+	if !errors.Is(res[0].Err, context.Canceled) || res[0].Attempts != 0 {
+		t.Errorf("result after cancel = %+v, want skipped source", res[0])
+	}
+}
+
+func TestBackoff_DoublesUpToCap(t *testing.T) {
+	tests := []struct {
+		base, attempt int
+		want          time.Duration
+	}{
+		{0, 0, defaultRetryBackoff},
+		{100, 0, 100 * time.Millisecond},
+		{100, 3, 800 * time.Millisecond},
+		{1000, 10, maxRetryBackoff},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.base, tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d, %d) = %v, want %v", tt.base, tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestHolder_SubstitutesLastGoodData(t *testing.T) {
+	now := time.Unix(0, 0)
+	h := NewHolder()
+	h.now = func() time.Time { return now }
+	src := config.Source{URL: "http://a.example", HoldLastGood: true, MaxStaleness: 1000}
+	good := []models.Data{{Name: "Alice", Value: "100"}}
+
+	h.Apply(&Result{Source: src, Data: good})
+
+	now = now.Add(500 * time.Millisecond)
+	failed := Result{Source: src, Err: &ScrapeError{Kind: KindTimeout, Err: errors.New("timeout")}}
+	h.Apply(&failed)
+	if !failed.Stale || len(failed.Data) != 1 || failed.Data[0].Value != "100" {
+		t.Fatalf("got %+v, want stale result holding last good data", failed)
+	}
+	if failed.StaleFor != 500*time.Millisecond {
+		t.Errorf("StaleFor = %v, want 500ms", failed.StaleFor)
+	}
+	if status := failed.Status(); !status.Stale || status.StaleMs != 500 || status.ErrorKind != string(KindTimeout) {
+		t.Errorf("Status() = %+v, want stale timeout status", status)
+	}
+
+	now = now.Add(time.Second)
+	expired := Result{Source: src, Err: &ScrapeError{Kind: KindTimeout, Err: errors.New("timeout")}}
+	h.Apply(&expired)
+	if expired.Stale || len(expired.Data) != 0 {
+		t.Errorf("got %+v, want held data dropped after max staleness", expired)
+	}
+}
+
+func TestHolder_RequiresHoldLastGood(t *testing.T) {
+	h := NewHolder()
+	src := config.Source{URL: "http://a.example"}
+
+	h.Apply(&Result{Source: src, Data: []models.Data{{Name: "Alice", Value: "100"}}})
+	failed := Result{Source: src, Err: errors.New("boom")}
+	h.Apply(&failed)
+
+	if failed.Stale || len(failed.Data) != 0 {
+		t.Errorf("got %+v, want failure left untouched", failed)
+	}
+}
+
+func TestHolder_KeysBySourceIdentity(t *testing.T) {
+	h := NewHolder()
+	table := config.Source{URL: "http://a.example", Mode: config.ModeTable, HoldLastGood: true}
+	equals := config.Source{URL: "http://a.example", Mode: config.ModeEquals, HoldLastGood: true}
+
+	h.Apply(&Result{Source: table, Data: []models.Data{{Name: "Alice", Value: "100"}}})
+	failed := Result{Source: equals, Err: errors.New("boom")}
+	h.Apply(&failed)
+	if failed.Stale {
+		t.Errorf("got %+v, want no data held from a source with another mode", failed)
+	}
+
+	h.Prune([]config.Source{equals})
+	failed = Result{Source: table, Err: errors.New("boom")}
+	h.Apply(&failed)
+	if failed.Stale {
+		t.Errorf("got %+v, want held data of a removed source dropped", failed)
+	}
+}
+
+func TestScrapeSources_ConditionalRequests(t *testing.T) {
+	var full atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`<p>Alice=100</p>`))
+	}))
+	defer ts.Close()
+	sources := []config.Source{{URL: ts.URL, Mode: config.ModeEquals, Enabled: true}}
+	opts := Options{Cache: NewCache()}
+
+	first := ScrapeSources(sources, opts)[0]
+	second := ScrapeSources(sources, opts)[0]
+
+	if first.NotModified {
+		t.Error("first response should not be served from cache")
+	}
+	if second.Err != nil || !second.NotModified {
+		t.Fatalf("second result = %+v, want 304 served from cache", second)
+	}
+	if len(second.Data) != 1 || second.Data[0].Value != "100" {
+		t.Errorf("Data = %+v, want cached line with value 100", second.Data)
+	}
+	if got := full.Load(); got != 1 {
+		t.Errorf("full responses = %d, want 1", got)
+	}
+	if status := second.Status(); !status.NotModified || status.CacheHitRate != 0.5 {
+		t.Errorf("Status() = %+v, want not modified with hit rate 0.5", status)
+	}
+}
+
+func TestCache_KeysBySourceIdentity(t *testing.T) {
+	var full atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`<p>Alice=100</p>`))
+	}))
+	defer ts.Close()
+	equals := config.Source{URL: ts.URL, Mode: config.ModeEquals, Enabled: true}
+	css := config.Source{URL: ts.URL, Mode: config.ModeCSS, Enabled: true, Selectors: config.Selectors{Row: "p"}}
+	opts := Options{Cache: NewCache()}
+
+	ScrapeSources([]config.Source{equals}, opts)
+	res := ScrapeSources([]config.Source{css}, opts)[0]
+	if res.NotModified || full.Load() != 2 {
+		t.Errorf("got %+v after %d full responses, want a full response for the css source", res, full.Load())
+	}
+
+	// The equals source was dropped from the cache by the last scrape
+	if res := ScrapeSources([]config.Source{equals}, opts)[0]; res.NotModified {
+		t.Error("removed source was answered from the cache")
+	}
+}
+
+func TestScraper_PrunesCollectors(t *testing.T) {
+	s := New(Settings{})
+	a := config.Source{URL: "http://a.example", Mode: config.ModeTable}
+	b := config.Source{URL: "http://a.example", Mode: config.ModeEquals}
+
+	if s.collector(a) == s.collector(b) {
+		t.Error("sources with different modes share a collector")
+	}
+	s.Prune([]config.Source{b})
+	if _, ok := s.collectors[a.ID()]; ok || len(s.collectors) != 1 {
+		t.Errorf("collectors = %v, want only the kept source", s.collectors)
+	}
+}
+
+func TestScrapeSource_NotModifiedWithoutCache(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer ts.Close()
+
+	res := ScrapeURL(ts.URL, config.ModeEquals)
+
+	var se *ScrapeError
+	if !errors.As(res.Err, &se) || se.Kind != KindHTTP {
+		t.Errorf("Err = %v, want http error", res.Err)
+	}
+}
+
+func TestScraper_ReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<p>Alice=100</p>`))
+	}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+	s := New(Settings{UserAgent: "poll-scraper-test"})
+	defer s.Close()
+	sources := []config.Source{{URL: ts.URL, Mode: config.ModeEquals, Enabled: true}}
+
+	for range 3 {
+		if res := s.ScrapeSources(sources, Options{})[0]; res.Err != nil {
+			t.Fatalf("unexpected error: %v", res.Err)
+		}
+	}
+
+	if got := conns.Load(); got != 1 {
+		t.Errorf("opened %d connections, want 1", got)
+	}
+}
+
+func TestScraper_SetsUserAgent(t *testing.T) {
+	agents := make(chan string, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents <- r.UserAgent()
+	}))
+	defer ts.Close()
+	s := New(Settings{UserAgent: "poll-scraper-test"})
+	defer s.Close()
+
+	s.ScrapeSource(config.Source{URL: ts.URL, Mode: config.ModeEquals, Enabled: true})
+
+	if got := <-agents; got != "poll-scraper-test" {
+		t.Errorf("User-Agent = %q, want %q", got, "poll-scraper-test")
+	}
+}
+
+func newLoginServer(t *testing.T, logins *atomic.Int32, session *atomic.Value) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			_, _ = w.Write([]byte(`<form method="post"></form>`))
+			return
+		}
+		if r.FormValue("user") != "alice" || r.FormValue("pass") != "secret" || r.FormValue("csrf") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		logins.Add(1)
+		id := fmt.Sprintf("s%d", logins.Load())
+		session.Store(id)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: id, Path: "/"})
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie("session")
+		if err != nil || c.Value != session.Load() {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte(`<p>Alice=100</p>`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestScraper_LogsInAndRenewsSession(t *testing.T) {
+	var logins atomic.Int32
+	var session atomic.Value
+	session.Store("")
+	ts := newLoginServer(t, &logins, &session)
+	defer ts.Close()
+	s := New(Settings{})
+	defer s.Close()
+	src := config.Source{
+		URL:     ts.URL + "/",
+		Mode:    config.ModeEquals,
+		Enabled: true,
+		Login: &config.Login{
+			URL:           ts.URL + "/login",
+			UsernameField: "user",
+			PasswordField: "pass",
+			Username:      "alice",
+			Password:      "secret",
+			Fields:        map[string]string{"csrf": "token"},
+		},
+	}
+
+	for range 2 {
+		if res := s.ScrapeSource(src); res.Err != nil || len(res.Data) != 1 {
+			t.Fatalf("got %+v, want one line", res)
+		}
+	}
+	if got := logins.Load(); got != 1 {
+		t.Errorf("logins = %d, want 1 while the session is valid", got)
+	}
+
+	session.Store("expired")
+	res := s.ScrapeSource(src)
+
+	if res.Err != nil || len(res.Data) != 1 || res.Data[0].Value != "100" {
+		t.Fatalf("got %+v, want data after logging in again", res)
+	}
+	if got := logins.Load(); got != 2 {
+		t.Errorf("logins = %d, want 2 after the session expired", got)
+	}
+}
+
+func TestScraper_LoginFailure(t *testing.T) {
+	var logins atomic.Int32
+	var session atomic.Value
+	session.Store("")
+	ts := newLoginServer(t, &logins, &session)
+	defer ts.Close()
+	s := New(Settings{})
+	defer s.Close()
+
+	res := s.ScrapeSource(config.Source{
+		URL:     ts.URL + "/",
+		Mode:    config.ModeEquals,
+		Enabled: true,
+		Login:   &config.Login{URL: ts.URL + "/login", UsernameField: "user", PasswordField: "pass", Username: "alice", Password: "wrong"},
+	})
+
+	var se *ScrapeError
+	if !errors.As(res.Err, &se) || se.Kind != KindAuth {
+		t.Errorf("Err = %v, want auth error", res.Err)
+	}
+}
+
+func newProxyServer(t *testing.T, auth *atomic.Value) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth.Store(r.Header.Get("Proxy-Authorization"))
+		fmt.Fprintf(w, "<p>Host=%s</p>", r.URL.Host)
+	}))
+}
+
+func TestScraper_UsesGlobalProxy(t *testing.T) {
+	var auth atomic.Value
+	proxy := newProxyServer(t, &auth)
+	defer proxy.Close()
+	cfg := &config.Config{Proxy: &config.Proxy{URL: proxy.URL, Username: "bob", Password: "pw"}}
+	s := New(SettingsFromConfig(cfg))
+	defer s.Close()
+
+	res := s.ScrapeSource(config.Source{URL: "http://poll.example/results", Mode: config.ModeEquals, Enabled: true})
+
+	if res.Err != nil || len(res.Data) != 1 || res.Data[0].Value != "poll.example" {
+		t.Fatalf("got %+v, want the page served through the proxy", res)
+	}
+	if got, _ := auth.Load().(string); !strings.HasPrefix(got, "Basic ") {
+		t.Errorf("Proxy-Authorization = %q, want basic auth", got)
+	}
+}
+
+func TestScraper_SourceProxyOverridesGlobal(t *testing.T) {
+	var globalAuth, sourceAuth atomic.Value
+	global := newProxyServer(t, &globalAuth)
+	defer global.Close()
+	source := newProxyServer(t, &sourceAuth)
+	defer source.Close()
+	s := New(Settings{Proxy: global.URL})
+	defer s.Close()
+
+	res := s.ScrapeSource(config.Source{
+		URL:     "http://poll.example/",
+		Mode:    config.ModeEquals,
+		Enabled: true,
+		Proxy:   &config.Proxy{URL: source.URL},
+	})
+
+	if res.Err != nil || len(res.Data) != 1 {
+		t.Fatalf("got %+v, want the page served through the source proxy", res)
+	}
+	if globalAuth.Load() != nil {
+		t.Error("global proxy should not be used when the source sets its own")
+	}
+}
+
+func TestScraper_NoProxyBypassesProxy(t *testing.T) {
+	var auth atomic.Value
+	proxy := newProxyServer(t, &auth)
+	defer proxy.Close()
+	s := New(Settings{Proxy: proxy.URL, NoProxy: "direct.invalid,10.0.0.0/8"})
+	defer s.Close()
+
+	res := s.ScrapeSource(config.Source{URL: "http://direct.invalid/", Mode: config.ModeEquals, Enabled: true})
+
+	if res.Err == nil {
+		t.Error("expected a direct connection to an unresolvable host to fail")
+	}
+	if auth.Load() != nil {
+		t.Error("proxy should not be used for hosts in no_proxy")
+	}
+}
+
+func encode1257(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := charmap.Windows1257.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		t.Fatalf("failed to encode %q: %v", s, err)
+	}
+	return b
+}
+
+func TestScrapeSource_DecodesCharsets(t *testing.T) {
+	const want = "Žemaitė Šarūnas"
+	tests := []struct {
+		name        string
+		contentType string
+		page        string
+		charset     string
+	}{
+		{"meta tag", "text/html", `<html><head><meta charset="windows-1257"></head><body><p>` + want + `=1</p></body></html>`, ""},
+		{"header", "text/html; charset=ISO-8859-13", `<p>` + want + `=1</p>`, ""},
+		{"override", "text/html", `<p>` + want + `=1</p>`, "windows-1257"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := encode1257(t, tt.page)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write(body)
+			}))
+			defer ts.Close()
+
+			res := ScrapeSource(config.Source{URL: ts.URL, Mode: config.ModeEquals, Enabled: true, Charset: tt.charset})
+
+			if res.Err != nil || len(res.Data) != 1 || res.Data[0].Name != want {
+				t.Errorf("got %+v, want name %q", res.Data, want)
+			}
+			if res.Charset == "" {
+				t.Error("Charset should report the decoded charset")
+			}
+		})
+	}
+}
+
+func TestScrapeSource_NormalizesNumbers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`<p>Alice= 1.234,5 </p><p>Bob=12,5 %</p>`))
+	}))
+	defer ts.Close()
+
+	data := ScrapeSource(config.Source{URL: ts.URL, Mode: config.ModeEquals, Enabled: true, DecimalSeparator: ","}).Data
+
+	if len(data) != 2 || data[0].Value != "1.234,5" || data[0].Number != "1234.5" || data[1].Number != "12.5" {
+		t.Errorf("got %+v, want trimmed values with numbers 1234.5 and 12.5", data)
+	}
+}
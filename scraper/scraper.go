@@ -0,0 +1,206 @@
+package scraper
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/batijo/poll-scraper/config"
+)
+
+// Settings tunes the HTTP transport shared by every request of a Scraper.
+type Settings struct {
+	UserAgent          string
+	MaxIdleConns       int
+	IdleConnTimeout    time.Duration
+	TLSMinVersion      uint16
+	InsecureSkipVerify bool
+	// Proxy is the proxy URL, with credentials, used by sources without
+	// their own proxy. Empty falls back to the environment.
+	Proxy string
+	// NoProxy is a comma-separated list of hosts, domains and CIDRs that
+	// are reached directly.
+	NoProxy string
+}
+
+// SettingsFromConfig returns the transport settings configured in cfg.
+func SettingsFromConfig(cfg *config.Config) Settings {
+	return Settings{
+		UserAgent:          cfg.UserAgent,
+		MaxIdleConns:       cfg.MaxIdleConns,
+		IdleConnTimeout:    time.Duration(cfg.IdleConnTimeout) * time.Millisecond,
+		TLSMinVersion:      cfg.TLSVersion(),
+		InsecureSkipVerify: cfg.TLSSkipVerify,
+		Proxy:              cfg.Proxy.Address(),
+		NoProxy:            strings.Join(cfg.NoProxy, ","),
+	}
+}
+
+// Scraper scrapes sources through long-lived collectors sharing one
+// transport per proxy, so keep-alive connections and TLS sessions survive
+// between cycles. It is safe for concurrent use.
+type Scraper struct {
+	settings   Settings
+	transport  *http.Transport
+	mu         sync.Mutex
+	proxied    map[string]*http.Transport
+	collectors map[string]*collector
+}
+
+type collector struct {
+	timeout   time.Duration
+	login     *config.Login
+	proxy     string
+	c         *colly.Collector
+	jar       http.CookieJar
+	transport *http.Transport
+
+	// mu serializes logins; loggedIn is set once the jar holds a session.
+	mu       sync.Mutex
+	loggedIn bool
+}
+
+// defaultScraper serves the package-level scrape functions.
+var defaultScraper = New(Settings{})
+
+func New(settings Settings) *Scraper {
+	return &Scraper{
+		settings:   settings,
+		transport:  newTransport(settings, settings.Proxy),
+		proxied:    make(map[string]*http.Transport),
+		collectors: make(map[string]*collector),
+	}
+}
+
+func newTransport(s Settings, proxy string) *http.Transport {
+	t, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		t = &http.Transport{}
+	}
+	t = t.Clone()
+	if s.MaxIdleConns > 0 {
+		t.MaxIdleConns = s.MaxIdleConns
+		t.MaxIdleConnsPerHost = s.MaxIdleConns
+	}
+	if s.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = s.IdleConnTimeout
+	}
+	if proxy != "" {
+		proxyURL := (&httpproxy.Config{HTTPProxy: proxy, HTTPSProxy: proxy, NoProxy: s.NoProxy}).ProxyFunc()
+		t.Proxy = func(r *http.Request) (*url.URL, error) {
+			return proxyURL(r.URL)
+		}
+	}
+	t.TLSClientConfig = &tls.Config{
+		MinVersion:         s.TLSMinVersion,
+		InsecureSkipVerify: s.InsecureSkipVerify, //nolint:gosec // opt-in for sources with self-signed certificates
+	}
+	return t
+}
+
+// Settings returns the settings the scraper was built with.
+func (s *Scraper) Settings() Settings {
+	return s.settings
+}
+
+// Close releases the idle connections held by the scraper.
+func (s *Scraper) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transport.CloseIdleConnections()
+	for _, t := range s.proxied {
+		t.CloseIdleConnections()
+	}
+}
+
+// transportFor returns the transport for a source proxy address. Callers
+// must hold s.mu.
+func (s *Scraper) transportFor(proxy string) *http.Transport {
+	if proxy == "" {
+		return s.transport
+	}
+	t, ok := s.proxied[proxy]
+	if !ok {
+		t = newTransport(s.settings, proxy)
+		s.proxied[proxy] = t
+	}
+	return t
+}
+
+// collector returns the collector for src, by its ID, creating it on first
+// use or when the source's timeout, login or proxy changed.
+func (s *Scraper) collector(src config.Source) *collector {
+	timeout := time.Duration(src.Timeout) * time.Millisecond
+	proxy := src.Proxy.Address()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := src.ID()
+	c, ok := s.collectors[id]
+	if ok && c.timeout == timeout && c.proxy == proxy && reflect.DeepEqual(c.login, src.Login) {
+		return c
+	}
+	jar, _ := cookiejar.New(nil) //nolint:errcheck // cookiejar.New never fails without options
+	t := s.transportFor(proxy)
+	c = &collector{
+		timeout:   timeout,
+		login:     src.Login,
+		proxy:     proxy,
+		c:         s.newCollector(timeout, jar, t),
+		jar:       jar,
+		transport: t,
+	}
+	s.collectors[id] = c
+	return c
+}
+
+// Prune drops the collectors, with their sessions, of every source not in
+// sources.
+func (s *Scraper) Prune(sources []config.Source) {
+	keep := sourceIDs(sources)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.collectors {
+		if !keep[id] {
+			delete(s.collectors, id)
+		}
+	}
+}
+
+// newCollector builds a collector whose callbacks read the request state
+// from the colly context, so one collector serves any number of visits.
+func (s *Scraper) newCollector(timeout time.Duration, jar http.CookieJar, t *http.Transport) *colly.Collector {
+	c := colly.NewCollector(colly.AllowURLRevisit())
+	c.SetCookieJar(jar)
+	if s.settings.UserAgent != "" {
+		c.UserAgent = s.settings.UserAgent
+	}
+	c.WithTransport(t)
+	if timeout > 0 {
+		c.SetRequestTimeout(timeout)
+	}
+	c.OnRequest(func(r *colly.Request) {
+		v := visitFrom(r.Ctx)
+		applySource(v.src, r.Headers)
+		r.ResponseCharacterEncoding = v.src.Charset
+		if v.cache != nil {
+			v.cache.setValidators(v.id, r.Headers)
+		}
+		slog.Debug("sending request", "url", v.src.URL, "headers", maskedHeader(*r.Headers))
+	})
+	c.OnResponse(func(r *colly.Response) {
+		visitFrom(r.Ctx).response(r)
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		visitFrom(r.Ctx).error(r, err)
+	})
+	return c
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"github.com/gocolly/colly/v2"
+	"golang.org/x/net/html"
+
+	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/models"
+)
+
+// xpathExtractor reads names and values through XPath expressions. With a
+// row expression, name and value are evaluated relative to each row;
+// otherwise they are evaluated on the whole document and paired by position.
+type xpathExtractor struct {
+	row, name, value *xpath.Expr
+}
+
+func newXPathExtractor(src config.Source) (Extractor, error) {
+	x := xpathExtractor{}
+	var err error
+	if src.Selectors.Row != "" {
+		if x.row, err = xpath.Compile(src.Selectors.Row); err != nil {
+			return nil, fmt.Errorf("invalid row expression: %w", err)
+		}
+	}
+	if x.name, err = xpath.Compile(src.Selectors.Name); err != nil {
+		return nil, fmt.Errorf("invalid name expression: %w", err)
+	}
+	if x.value, err = xpath.Compile(src.Selectors.Value); err != nil {
+		return nil, fmt.Errorf("invalid value expression: %w", err)
+	}
+	return x, nil
+}
+
+func (x xpathExtractor) Extract(resp *colly.Response) ([]models.Data, error) {
+	doc, err := htmlquery.Parse(bytes.NewReader(resp.Body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	var data []models.Data
+	if x.row == nil {
+		names := htmlquery.QuerySelectorAll(doc, x.name)
+		values := htmlquery.QuerySelectorAll(doc, x.value)
+		if len(names) != len(values) {
+			return nil, fmt.Errorf("name and value expressions matched %d and %d nodes", len(names), len(values))
+		}
+		for i := range names {
+			data = append(data, models.Data{Name: nodeText(names[i]), Value: nodeText(values[i])})
+		}
+		return data, nil
+	}
+
+	for _, row := range htmlquery.QuerySelectorAll(doc, x.row) {
+		name := htmlquery.QuerySelector(row, x.name)
+		value := htmlquery.QuerySelector(row, x.value)
+		if name == nil || value == nil {
+			continue
+		}
+		data = append(data, models.Data{Name: nodeText(name), Value: nodeText(value)})
+	}
+	return data, nil
+}
+
+func nodeText(n *html.Node) string {
+	return strings.TrimSpace(htmlquery.InnerText(n))
+}
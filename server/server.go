@@ -9,6 +9,9 @@ import (
 
 	"github.com/batijo/poll-scraper/api/handlers"
 	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/hub"
+	"github.com/batijo/poll-scraper/scraper"
+	"github.com/batijo/poll-scraper/snapshot"
 )
 
 const readHeaderTimeout = 10 * time.Second
@@ -18,9 +21,15 @@ type Server struct {
 	mux *http.ServeMux
 }
 
-func New(cfg *config.Config) *Server {
+func New(cfg *config.Config, sc *scraper.Scraper, store *snapshot.Store, h *hub.Hub) *Server {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", handlers.Data(cfg))
+	mux.HandleFunc("/", handlers.Data(cfg, sc, store))
+	mux.HandleFunc("/raw", handlers.Raw(cfg, store))
+	mux.HandleFunc("/status", handlers.Status(store))
+	mux.HandleFunc("/healthz", handlers.Health)
+	mux.HandleFunc("/readyz", handlers.Ready(cfg, store))
+	mux.HandleFunc("/events", handlers.Stream(store))
+	mux.HandleFunc("/ws", h.Handler(cfg.Domains))
 	handler := withMiddleware(mux, cfg)
 	return &Server{
 		Server: &http.Server{
@@ -6,11 +6,14 @@ import (
 	"testing"
 
 	"github.com/batijo/poll-scraper/config"
+	"github.com/batijo/poll-scraper/hub"
+	"github.com/batijo/poll-scraper/scraper"
+	"github.com/batijo/poll-scraper/snapshot"
 )
 
 func TestWithMiddleware_SetsHeaders(t *testing.T) {
 	cfg := &config.Config{
-		Links:   []string{},
+		Sources: []config.Source{},
 		Port:    3000,
 		Domains: []string{},
 	}
@@ -37,7 +40,7 @@ func TestWithMiddleware_SetsHeaders(t *testing.T) {
 
 func TestWithMiddleware_OptionsRequest(t *testing.T) {
 	cfg := &config.Config{
-		Links:   []string{},
+		Sources: []config.Source{},
 		Port:    3000,
 		Domains: []string{},
 	}
@@ -58,7 +61,7 @@ func TestWithMiddleware_OptionsRequest(t *testing.T) {
 
 func TestWithMiddleware_CustomOrigins(t *testing.T) {
 	cfg := &config.Config{
-		Links:   []string{},
+		Sources: []config.Source{},
 		Port:    3000,
 		Domains: []string{"https://example.com"},
 	}
@@ -111,11 +114,12 @@ func TestWriteError(t *testing.T) {
 
 func TestNew_ReturnsServer(t *testing.T) {
 	cfg := &config.Config{
-		Links: []string{},
-		Port:  3000,
+		Sources: []config.Source{},
+		Port:    3000,
 	}
 
-	srv := New(cfg)
+	store := snapshot.NewStore()
+	srv := New(cfg, scraper.New(scraper.Settings{}), store, hub.New(store, nil))
 
 	if srv == nil || srv.Server == nil {
 		t.Fatal("New() returned nil or Server.Server is nil")
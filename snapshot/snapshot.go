@@ -0,0 +1,146 @@
+// Package snapshot shares the result of the latest scrape cycle between the
+// writer loop, which publishes it, and the HTTP server, which serves it
+// without scraping again.
+package snapshot
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/batijo/poll-scraper/models"
+)
+
+const etagLength = 16
+
+// Scraper states, as emitted in the polled:state UI event.
+const (
+	StateScraping = "scraping"
+	StateIdle     = "idle"
+	StateError    = "error"
+	StateStopped  = "stopped"
+)
+
+// Snapshot is the result of one scrape cycle. A published snapshot is never
+// modified.
+type Snapshot struct {
+	// Seq numbers the snapshots of a store from 1, across writer restarts.
+	Seq      uint64
+	Cycle    int
+	Data     []models.Data
+	RawData  []models.Data
+	Statuses []models.URLStatus
+	// Body is Data encoded as JSON and ETag a strong validator of it.
+	Body []byte
+	ETag string
+	// Published is when the snapshot was published and Modified when its
+	// Data last changed.
+	Published time.Time
+	Modified  time.Time
+}
+
+// Payload returns the snapshot in the form of the polled:data UI event.
+func (s *Snapshot) Payload() models.DataPayload {
+	return models.NewDataPayload(s.Data, s.RawData, s.Published)
+}
+
+// StaleSources returns the URLs of the sources that served held data.
+func (s *Snapshot) StaleSources() []string {
+	var stale []string
+	for _, st := range s.Statuses {
+		if st.Stale {
+			stale = append(stale, st.URL)
+		}
+	}
+	return stale
+}
+
+// Store holds the latest snapshot and the scraper state. It is safe for
+// concurrent use.
+type Store struct {
+	mu     sync.RWMutex
+	latest *Snapshot
+	seq    uint64
+	state  string
+	subs   map[chan *Snapshot]struct{}
+	now    func() time.Time
+}
+
+func NewStore() *Store {
+	return &Store{state: StateStopped, subs: make(map[chan *Snapshot]struct{}), now: time.Now}
+}
+
+// Publish encodes snap and makes it the latest snapshot. Modified is carried
+// over from the previous snapshot when the data did not change.
+func (s *Store) Publish(snap Snapshot) error {
+	if snap.Data == nil {
+		snap.Data = []models.Data{}
+	}
+	body, err := json.Marshal(snap.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	snap.Body = append(body, '\n')
+	sum := sha256.Sum256(snap.Body)
+	snap.ETag = `"` + hex.EncodeToString(sum[:])[:etagLength] + `"`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	snap.Seq = s.seq
+	snap.Published = s.now()
+	snap.Modified = snap.Published
+	if s.latest != nil && s.latest.ETag == snap.ETag {
+		snap.Modified = s.latest.Modified
+	}
+	s.latest = &snap
+	for ch := range s.subs {
+		// Subscribers only care about the latest snapshot, so one they have
+		// not received yet is replaced rather than queued
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- s.latest
+	}
+	return nil
+}
+
+// Subscribe returns a channel that receives every snapshot published from
+// now on. A slow subscriber skips to the latest snapshot instead of blocking
+// Publish. Call cancel to unsubscribe.
+func (s *Store) Subscribe() (updates <-chan *Snapshot, cancel func()) {
+	ch := make(chan *Snapshot, 1)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}
+}
+
+// Latest returns the latest snapshot, or false when none was published yet.
+func (s *Store) Latest() (*Snapshot, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.latest, s.latest != nil
+}
+
+// SetState records the current scraper state.
+func (s *Store) SetState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// State returns the current scraper state.
+func (s *Store) State() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
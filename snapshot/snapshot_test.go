@@ -0,0 +1,77 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/batijo/poll-scraper/models"
+)
+
+func TestPublish_KeepsModifiedWhileDataIsUnchanged(t *testing.T) {
+	store := NewStore()
+	now := time.Unix(1000, 0)
+	store.now = func() time.Time { return now }
+	data := []models.Data{{Name: "A", Value: "1"}}
+
+	if err := store.Publish(Snapshot{Cycle: 1, Data: data}); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	first, _ := store.Latest()
+	now = now.Add(time.Second)
+	_ = store.Publish(Snapshot{Cycle: 2, Data: data})
+	second, _ := store.Latest()
+
+	if second.ETag != first.ETag || !second.Modified.Equal(first.Modified) || second.Published.Equal(first.Published) {
+		t.Errorf("unchanged data: ETag %s→%s, Modified %v→%v", first.ETag, second.ETag, first.Modified, second.Modified)
+	}
+
+	now = now.Add(time.Second)
+	_ = store.Publish(Snapshot{Cycle: 3, Data: []models.Data{{Name: "A", Value: "2"}}})
+	third, _ := store.Latest()
+	if third.ETag == second.ETag || !third.Modified.Equal(now) {
+		t.Errorf("changed data kept ETag %s or Modified %v", third.ETag, third.Modified)
+	}
+}
+
+func TestLatest_EmptyStore(t *testing.T) {
+	if _, ok := NewStore().Latest(); ok {
+		t.Error("Latest() on an empty store returned a snapshot")
+	}
+}
+
+func TestStaleSources(t *testing.T) {
+	snap := Snapshot{Statuses: []models.URLStatus{{URL: "http://a", Stale: true}, {URL: "http://b"}}}
+
+	if got := snap.StaleSources(); len(got) != 1 || got[0] != "http://a" {
+		t.Errorf("StaleSources() = %v, want [http://a]", got)
+	}
+}
+
+func TestSubscribe_SkipsToLatest(t *testing.T) {
+	store := NewStore()
+	updates, cancel := store.Subscribe()
+	defer cancel()
+
+	_ = store.Publish(Snapshot{Cycle: 1})
+	_ = store.Publish(Snapshot{Cycle: 2})
+
+	if snap := <-updates; snap.Seq != 2 || snap.Cycle != 2 {
+		t.Errorf("got snapshot %d of cycle %d, want the latest", snap.Seq, snap.Cycle)
+	}
+	select {
+	case snap := <-updates:
+		t.Errorf("unexpected queued snapshot %d", snap.Seq)
+	default:
+	}
+}
+
+func TestState(t *testing.T) {
+	store := NewStore()
+	if got := store.State(); got != StateStopped {
+		t.Errorf("initial State() = %q, want %q", got, StateStopped)
+	}
+	store.SetState(StateScraping)
+	if got := store.State(); got != StateScraping {
+		t.Errorf("State() = %q, want %q", got, StateScraping)
+	}
+}
@@ -15,7 +15,9 @@ import (
 
 	"github.com/batijo/poll-scraper/config"
 	"github.com/batijo/poll-scraper/models"
+	"github.com/batijo/poll-scraper/pipeline"
 	"github.com/batijo/poll-scraper/scraper"
+	"github.com/batijo/poll-scraper/snapshot"
 	"github.com/batijo/poll-scraper/utils"
 )
 
@@ -25,9 +27,12 @@ type EventEmitter interface {
 	EmitScraperError(message string)
 	EmitURLStatus(statuses []models.URLStatus)
 	RequestScraperStop()
+	MigrateFilterLines(rawData []models.Data)
 }
 
-func StartWriting(cfg *config.Config, emitter EventEmitter) (context.CancelFunc, error) {
+// StartWriting starts the scrape loop, which writes the output files and
+// publishes the result of every cycle to store.
+func StartWriting(cfg *config.Config, sc *scraper.Scraper, store *snapshot.Store, emitter EventEmitter) (context.CancelFunc, error) {
 	if cfg.UpdateInterval < 0 {
 		slog.Error("update_interval cannot be negative")
 		return nil, fmt.Errorf("invalid value")
@@ -35,16 +40,30 @@ func StartWriting(cfg *config.Config, emitter EventEmitter) (context.CancelFunc,
 	if cfg.UpdateInterval < utils.MinIntervalWarn {
 		slog.Warn("setting update_interval too low might cause high CPU usage and/or server load")
 	}
-	slog.Info("scraper started", "interval", cfg.UpdateInterval, "urls", len(cfg.Links))
+	pl, err := pipeline.New(cfg)
+	if err != nil {
+		slog.Error("invalid processing pipeline", "err", err)
+		return nil, err
+	}
+	slog.Info("scraper started", "interval", cfg.UpdateInterval, "urls", len(cfg.ActiveSources()))
 	ctx, cancel := context.WithCancel(context.Background())
-	go writer(ctx, cfg, emitter)
+	go writer(ctx, cfg, sc, pl, store, emitter)
 	return cancel, nil
 }
 
 //nolint:gocyclo,funlen // main scrape loop with inherent complexity
-func writer(ctx context.Context, cfg *config.Config, emitter EventEmitter) {
+func writer(
+	ctx context.Context, cfg *config.Config, sc *scraper.Scraper, pl *pipeline.Pipeline, store *snapshot.Store, emitter EventEmitter,
+) {
 	cycle := 0
 	expectedLineCounts := make(map[string]int)
+	migrationRequested := false
+	opts := scraper.Options{
+		Workers: cfg.MaxConcurrency,
+		Hold:    scraper.NewHolder(),
+		Cache:   scraper.NewCache(),
+		Context: ctx,
+	}
 
 	for {
 		select {
@@ -53,72 +72,73 @@ func writer(ctx context.Context, cfg *config.Config, emitter EventEmitter) {
 			return
 		default:
 		}
-		emitter.EmitScraperState("scraping")
+		emitter.EmitScraperState(snapshot.StateScraping)
 		cycle++
 
 		start := time.Now()
-		lines := cfg.FilterLinesZeroIndexed()
 
+		sources := cfg.ActiveSources()
 		var data []models.Data
-		statuses := make([]models.URLStatus, 0, len(cfg.Links))
+		statuses := make([]models.URLStatus, 0, len(sources))
 		lineCountChanged := false
-		for _, link := range cfg.Links {
-			urlData := scraper.ScrapeURL(link, cfg.WithEq)
-			status := models.URLStatus{
-				URL:       link,
-				HasData:   len(urlData) > 0,
-				LineCount: len(urlData),
-			}
-			if len(urlData) == 0 {
-				slog.Warn("no data from URL", "url", link)
+		complete := true
+		opts.Cycle = cycle
+		for _, res := range sc.ScrapeSources(sources, opts) {
+			src := res.Source
+			link := src.URL
+			urlData := res.Data
+			status := res.Status()
+			if res.Err != nil {
+				slog.Error("failed to scrape URL", "url", link, "cycle", cycle, "err", res.Err)
+				complete = false
+			} else if len(urlData) == 0 && !res.Stale {
+				slog.Warn("no data from URL", "url", link, "status", res.StatusCode)
 			}
 
-			if expected, ok := expectedLineCounts[link]; ok {
-				if len(urlData) != expected {
-					slog.Error("URL line count changed", "url", link, "expected", expected, "got", len(urlData))
-					emitter.EmitScraperError(fmt.Sprintf("URL line count changed for %s: expected %d, got %d", link, expected, len(urlData)))
-					status.Error = true
-					lineCountChanged = true
-				}
+			expected, ok := expectedLineCounts[link]
+			if !ok && src.ExpectedLines > 0 {
+				expected, ok = src.ExpectedLines, true
+			}
+			if ok && len(urlData) != expected {
+				slog.Error("URL line count changed", "url", link, "expected", expected, "got", len(urlData))
+				emitter.EmitScraperError(fmt.Sprintf("URL line count changed for %s: expected %d, got %d", src.Name(), expected, len(urlData)))
+				status.Error = true
+				lineCountChanged = true
+			}
+			if src.ExpectedLines == 0 {
+				expectedLineCounts[link] = len(urlData)
 			}
-			expectedLineCounts[link] = len(urlData)
 
 			statuses = append(statuses, status)
 			data = append(data, urlData...)
 		}
+		// A stopped or restarted writer must not publish, write or emit the
+		// result of a cycle that outlived it
+		if ctx.Err() != nil {
+			slog.Info("scraper stopped")
+			return
+		}
 		emitter.EmitURLStatus(statuses)
 
 		if lineCountChanged && cfg.StopOnLineCountChange {
-			emitter.EmitScraperState("error")
+			emitter.EmitScraperState(snapshot.StateError)
 			emitter.RequestScraperStop()
 			return
 		}
 
 		// rawData = URL-scraped data only (for frontend filter modal)
-		rawData := make([]models.Data, len(data))
-		copy(rawData, data)
+		rawData := data
 
-		rawCount := len(data)
-		if len(lines) > 0 {
-			data = models.FilterData(lines, data)
-			slog.Debug("filtered lines", "before", rawCount, "after", len(data))
+		// Positional filters are only converted from a complete scrape, as a
+		// missing source would shift the lines they point to
+		if len(cfg.FilterLines) > 0 && complete && !lineCountChanged && !migrationRequested {
+			emitter.MigrateFilterLines(rawData)
+			migrationRequested = true
 		}
 
-		// Add non-filtered custom lines after URL filtering
-		if len(cfg.AddLines) > 0 {
-			var addLines []models.Data
-			for _, l := range cfg.AddLines {
-				if !l.Filtered {
-					addLines = append(addLines, models.Data{Name: l.Name, Value: l.Value})
-				}
-			}
-			if len(addLines) > 0 {
-				data = models.AddLines(data, addLines)
-				slog.Debug("added custom lines", "count", len(addLines))
-			}
-		}
-		if cfg.AddSum {
-			data = models.SumData(data, cfg.SumSymbols)
+		data = pl.Run(rawData).Data
+		if err := store.Publish(snapshot.Snapshot{Cycle: cycle, Data: data, RawData: rawData, Statuses: statuses}); err != nil {
+			slog.Error("failed to publish snapshot", "err", err)
 		}
 
 		hasError := false
@@ -145,9 +165,9 @@ func writer(ctx context.Context, cfg *config.Config, emitter EventEmitter) {
 		slog.Debug("scrape cycle complete", "cycle", cycle, "lines", len(data), "took", elapsed.Round(time.Millisecond))
 
 		if hasError {
-			emitter.EmitScraperState("error")
+			emitter.EmitScraperState(snapshot.StateError)
 		} else {
-			emitter.EmitScraperState("idle")
+			emitter.EmitScraperState(snapshot.StateIdle)
 		}
 		remaining := time.Duration(cfg.UpdateInterval)*time.Millisecond - elapsed
 		if remaining > 0 {
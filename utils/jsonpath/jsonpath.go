@@ -0,0 +1,126 @@
+// Package jsonpath implements the small JSONPath subset used to select values
+// from JSON poll sources: dotted keys, array indices and [*] wildcards, with
+// an optional leading "$". For example "$.results[*].votes" or "data.items[0]".
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const wildcard = -1
+
+type step struct {
+	key   string
+	index int
+	isKey bool
+}
+
+// Path is a compiled JSONPath expression.
+type Path struct {
+	expr  string
+	steps []step
+}
+
+// Compile parses expr into a Path.
+func Compile(expr string) (*Path, error) {
+	rest := strings.TrimSpace(expr)
+	rest = strings.TrimPrefix(rest, "$")
+	p := &Path{expr: expr}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in %q", expr)
+			}
+			if key := rest[:end]; key == "*" {
+				p.steps = append(p.steps, step{index: wildcard})
+			} else {
+				p.steps = append(p.steps, step{key: key, isKey: true})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed bracket in %q", expr)
+			}
+			s, err := parseBracket(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("%w in %q", err, expr)
+			}
+			p.steps = append(p.steps, s)
+			rest = rest[end+1:]
+		default:
+			if len(p.steps) > 0 {
+				return nil, fmt.Errorf("unexpected %q in %q", rest[0], expr)
+			}
+			rest = "." + rest
+		}
+	}
+	return p, nil
+}
+
+func parseBracket(inner string) (step, error) {
+	inner = strings.TrimSpace(inner)
+	if inner == "*" {
+		return step{index: wildcard}, nil
+	}
+	if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+		return step{key: inner[1 : len(inner)-1], isKey: true}, nil
+	}
+	i, err := strconv.Atoi(inner)
+	if err != nil || i < 0 {
+		return step{}, fmt.Errorf("invalid index %q", inner)
+	}
+	return step{index: i}, nil
+}
+
+// String returns the source expression.
+func (p *Path) String() string {
+	return p.expr
+}
+
+// Eval returns every value in v selected by the path. v is a value decoded by
+// encoding/json into interface{}.
+func (p *Path) Eval(v any) []any {
+	current := []any{v}
+	for _, s := range p.steps {
+		var next []any
+		for _, c := range current {
+			next = append(next, s.apply(c)...)
+		}
+		current = next
+	}
+	return current
+}
+
+func (s step) apply(v any) []any {
+	if s.isKey {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		child, ok := obj[s.key]
+		if !ok {
+			return nil
+		}
+		return []any{child}
+	}
+	arr, ok := v.([]any)
+	if !ok {
+		return nil
+	}
+	if s.index == wildcard {
+		return arr
+	}
+	if s.index >= len(arr) {
+		return nil
+	}
+	return []any{arr[s.index]}
+}
@@ -42,7 +42,9 @@ func (h *FrontendHandler) Enabled(_ context.Context, level slog.Level) bool {
 func (h *FrontendHandler) Handle(ctx context.Context, r slog.Record) error {
 	var attrs []string
 	r.Attrs(func(a slog.Attr) bool {
-		attrs = append(attrs, fmt.Sprintf("%s=%v", a.Key, a.Value))
+		// Resolve so that slog.LogValuer implementations, such as masked
+		// secrets, are rendered the same way as by the inner handler.
+		attrs = append(attrs, fmt.Sprintf("%s=%v", a.Key, a.Value.Resolve()))
 		return true
 	})
 
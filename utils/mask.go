@@ -0,0 +1,14 @@
+package utils
+
+import "strings"
+
+const maskedLen = 8
+
+// Mask hides a secret value in log output. Empty values stay empty so that
+// unset fields remain recognizable.
+func Mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	return strings.Repeat("*", maskedLen)
+}